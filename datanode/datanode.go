@@ -0,0 +1,39 @@
+package datanode
+
+import (
+	_ "embed"
+	"flag"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+const namespace = "datanode"
+
+// defaultRules are the built-in rules mapping the beans to metrics.
+//
+//go:embed rules.yaml
+var defaultRules []byte
+
+// collectors send the metrics of the beans that the rules cannot express.
+var collectors = []lib.BeanCollector{lib.NewRPCCollector(namespace), lib.NewJVMCollector(namespace), peers{}, volumes{}}
+
+func registerFlags() {
+	flag.IntVar(&peerLimit, "datanode.peers.limit", peerLimit, "Maximum number of downstream peers, the slowest first, with metrics parsed from the SendPacketDownstreamAvgInfo of DataNodeInfo. 0 disables them, -1 removes the limit.")
+}
+
+// Role is the DataNode role of the exporter.
+var Role = &lib.Role{
+	Name:          "datanode",
+	Title:         "DataNode",
+	Service:       "DataNode",
+	Namespace:     namespace,
+	ListenAddress: ":9072",
+	URL:           "http://localhost:50075",
+	Path:          "/jmx",
+	LegacyURLFlag: "datanode.jmx.url",
+	RegisterFlags: registerFlags,
+	Rules:         defaultRules,
+	NewExporter: func(client *lib.JmxClient, rules *lib.Rules) lib.ContextCollector {
+		return lib.NewJmxExporter(client, rules, collectors...)
+	},
+}
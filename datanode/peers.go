@@ -0,0 +1,56 @@
+package datanode
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+// peerLimit is the maximum number of downstream peers with per-peer metrics.
+var peerLimit = 10
+
+var peerSendPacketDesc = lib.NewMetricDesc(namespace, "peer", "send_packet_downstream_avg_time_seconds", "Rolling average time of sending a packet to the downstream peer of the write pipeline", "peer")
+
+// rollingAvgTime matches the keys of SendPacketDownstreamAvgInfo, e.g.
+// [10.0.0.12:9866]RollingAvgTime.
+var rollingAvgTime = regexp.MustCompile(`^\[(.+)\]RollingAvgTime$`)
+
+// peers collects the SendPacketDownstreamAvgInfo of the DataNodeInfo bean,
+// the time taken to send packets to every downstream peer. It is null unless
+// dfs.datanode.peer.stats.enabled is set.
+type peers struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (peers) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	if bean.Name() != "Hadoop:service=DataNode,name=DataNodeInfo" || !bean.Has("SendPacketDownstreamAvgInfo") {
+		return
+	}
+	info, ok := bean.JSON("SendPacketDownstreamAvgInfo")
+	if !ok || peerLimit == 0 {
+		return
+	}
+
+	type peer struct {
+		name  string
+		value float64
+	}
+	var sorted []peer
+	for _, attr := range info.Keys() {
+		m := rollingAvgTime.FindStringSubmatch(attr)
+		if m == nil {
+			continue
+		}
+		if v, ok := info.Float(attr); ok {
+			sorted = append(sorted, peer{m[1], v})
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].value > sorted[j].value })
+	if peerLimit > 0 && len(sorted) > peerLimit {
+		sorted = sorted[:peerLimit]
+	}
+
+	for _, p := range sorted {
+		e.Gauge(peerSendPacketDesc, p.value/1000, p.name)
+	}
+}
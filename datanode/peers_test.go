@@ -0,0 +1,168 @@
+package datanode
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var descName = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// collect returns the series the collector sends for the beans of the /jmx
+// response, as name{label="value",...}, with their values.
+func collect(t *testing.T, c lib.BeanCollector, jmx string) map[string]float64 {
+	t.Helper()
+	beans, err := lib.ParseBeans([]byte(jmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric, 1000)
+	e := lib.NewEmitter(ch, nil, nil)
+	for _, bean := range beans {
+		c.CollectBean(e, bean)
+	}
+	close(ch)
+
+	values := map[string]float64{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		name := descName.FindStringSubmatch(m.Desc().String())
+		if name == nil {
+			t.Fatalf("unexpected descriptor %s", m.Desc())
+		}
+		var labels []string
+		for _, l := range pb.GetLabel() {
+			labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+		}
+		sort.Strings(labels)
+		value := pb.GetGauge().GetValue()
+		if pb.Counter != nil {
+			value = pb.GetCounter().GetValue()
+		}
+		values[name[1]+"{"+strings.Join(labels, ",")+"}"] = value
+	}
+	return values
+}
+
+func TestPeers(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jmx  string
+		want map[string]float64
+	}{
+		{
+			name: "peer stats",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+				"modelerType": "org.apache.hadoop.hdfs.server.datanode.DataNode",
+				"Version": "3.3.6",
+				"SendPacketDownstreamAvgInfo": "{\"[10.0.0.12:9866]RollingAvgTime\":1.5,\"[10.0.0.13:9866]RollingAvgTime\":0.25}",
+				"SlowDisks": null
+			}]}`,
+			want: map[string]float64{
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.12:9866"}`: 1.5 / 1000,
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.13:9866"}`: 0.25 / 1000,
+			},
+		},
+		{
+			name: "peer stats disabled",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+				"SendPacketDownstreamAvgInfo": null
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "before Hadoop 2.9",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+				"Version": "2.7.3"
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "empty object",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+				"SendPacketDownstreamAvgInfo": "{}"
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "malformed JSON",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+				"SendPacketDownstreamAvgInfo": "{\"[10.0.0.12:9866]RollingAvgTime\":"
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "keys and values of another shape",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+				"SendPacketDownstreamAvgInfo": "{\"10.0.0.12:9866\":1.5,\"[10.0.0.13:9866]RollingAvgTime\":\"slow\",\"[10.0.0.14:9866]RollingAvgTime\":{\"avg\":1},\"[10.0.0.15:9866]RollingAvgTime\":2}"
+			}]}`,
+			want: map[string]float64{
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.15:9866"}`: 2.0 / 1000,
+			},
+		},
+		{
+			name: "other bean",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=FSDatasetState",
+				"SendPacketDownstreamAvgInfo": "{\"[10.0.0.12:9866]RollingAvgTime\":1.5}"
+			}]}`,
+			want: map[string]float64{},
+		},
+	} {
+		if got := collect(t, peers{}, tt.jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPeerLimit(t *testing.T) {
+	defer func(limit int) { peerLimit = limit }(peerLimit)
+	jmx := `{"beans":[{
+		"name": "Hadoop:service=DataNode,name=DataNodeInfo",
+		"SendPacketDownstreamAvgInfo": "{\"[10.0.0.12:9866]RollingAvgTime\":1.5,\"[10.0.0.13:9866]RollingAvgTime\":0.25,\"[10.0.0.14:9866]RollingAvgTime\":4}"
+	}]}`
+
+	for _, tt := range []struct {
+		limit int
+		want  map[string]float64
+	}{
+		{limit: 0, want: map[string]float64{}},
+		{
+			limit: 2,
+			want: map[string]float64{
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.14:9866"}`: 4.0 / 1000,
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.12:9866"}`: 1.5 / 1000,
+			},
+		},
+		{
+			limit: -1,
+			want: map[string]float64{
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.14:9866"}`: 4.0 / 1000,
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.12:9866"}`: 1.5 / 1000,
+				`datanode_peer_send_packet_downstream_avg_time_seconds{peer="10.0.0.13:9866"}`: 0.25 / 1000,
+			},
+		},
+	} {
+		peerLimit = tt.limit
+		if got := collect(t, peers{}, jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limit %d: got %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package datanode
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+// volumeOps names the op label of the file IO timings of DataNodeVolume by
+// attribute prefix.
+var volumeOps = map[string]string{
+	"MetadataOperation": "metadata_operation",
+	"ReadIo":            "read_io",
+	"WriteIo":           "write_io",
+	"SyncIo":            "sync_io",
+	"FlushIo":           "flush_io",
+	"DataFileIo":        "data_file_io",
+}
+
+var (
+	volumeIOsDesc         = lib.NewMetricDesc(namespace, "volume", "io_total", "Total number of file IO operations on the volume", "disk", "op")
+	volumeAvgTimeDesc     = lib.NewMetricDesc(namespace, "volume", "io_avg_time_seconds", "Average time of the file IO operations on the volume in the last interval", "disk", "op")
+	volumeLatencyDesc     = lib.NewMetricDesc(namespace, "volume", "io_latency_seconds", "Quantile of the time of the file IO operations on the volume over the last interval", "disk", "op", "interval", "quantile")
+	volumeIntervalOpsDesc = lib.NewMetricDesc(namespace, "volume", "io_latency_interval_ops", "Number of file IO operations on the volume over the last interval of the quantiles", "disk", "op", "interval")
+	volumeErrorsDesc      = lib.NewMetricDesc(namespace, "volume", "file_io_errors_total", "Total number of failed file IO operations on the volume", "disk")
+)
+
+var (
+	// volumeRate matches the rates of DataNodeVolume, e.g. ReadIoRateNumOps or
+	// ReadIoRateAvgTime.
+	volumeRate = regexp.MustCompile(`^(MetadataOperation|ReadIo|WriteIo|SyncIo|FlushIo|DataFileIo)Rate(NumOps|AvgTime)$`)
+	// volumeQuantile matches the percentiles of the latencies over an
+	// interval, e.g. ReadIoLatency60s99thPercentileLatency.
+	volumeQuantile = regexp.MustCompile(`^(MetadataOperation|ReadIo|WriteIo|SyncIo|FlushIo|DataFileIo)Latency(\d+)s(\d+)thPercentileLatency$`)
+	// volumeIntervalOps matches the number of operations the percentiles are
+	// taken over, e.g. ReadIoLatency60sNumOps.
+	volumeIntervalOps = regexp.MustCompile(`^(MetadataOperation|ReadIo|WriteIo|SyncIo|FlushIo|DataFileIo)Latency(\d+)sNumOps$`)
+)
+
+// volumes collects the DataNodeVolume-<disk> beans, the file IO latencies of
+// every volume of the DataNode, so a sick disk stands out. They are there only
+// with dfs.datanode.fileio.profiling.sampling.percentage set, and the
+// quantiles with dfs.metrics.percentiles.intervals too.
+type volumes struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (volumes) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	const prefix = "Hadoop:service=DataNode,name=DataNodeVolume-"
+	if !strings.HasPrefix(bean.Name(), prefix) {
+		return
+	}
+	disk := strings.TrimPrefix(bean.Name(), prefix)
+
+	for _, attr := range bean.Keys() {
+		if attr == "FileIoErrorRateNumOps" {
+			e.BeanCounter(volumeErrorsDesc, bean, attr, disk)
+		}
+
+		if m := volumeRate.FindStringSubmatch(attr); m != nil {
+			switch m[2] {
+			case "NumOps":
+				e.BeanCounter(volumeIOsDesc, bean, attr, disk, volumeOps[m[1]])
+			case "AvgTime":
+				if v, ok := bean.Float(attr); ok {
+					e.Gauge(volumeAvgTimeDesc, v/1000, disk, volumeOps[m[1]])
+				}
+			}
+		}
+
+		// The percentiles and their count are those of the last interval,
+		// rolled over every interval, so they are gauges.
+		if m := volumeQuantile.FindStringSubmatch(attr); m != nil {
+			percentile, _ := strconv.ParseFloat(m[3], 64)
+			if v, ok := bean.Float(attr); ok {
+				e.Gauge(volumeLatencyDesc, v/1000, disk, volumeOps[m[1]], m[2]+"s", strconv.FormatFloat(percentile/100, 'f', -1, 64))
+			}
+		}
+		if m := volumeIntervalOps.FindStringSubmatch(attr); m != nil {
+			e.BeanGauge(volumeIntervalOpsDesc, bean, attr, disk, volumeOps[m[1]], m[2]+"s")
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package datanode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVolumes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jmx  string
+		want map[string]float64
+	}{
+		{
+			name: "file IO profiling",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeVolume-/data/1/dfs/dn",
+				"modelerType": "DataNodeVolume-/data/1/dfs/dn",
+				"tag.Context": "dfs",
+				"tag.Hostname": "dn1.example.com",
+				"TotalMetadataOperations": 120,
+				"MetadataOperationRateNumOps": 120,
+				"MetadataOperationRateAvgTime": 0.5,
+				"TotalDataFileIos": 3000,
+				"DataFileIoRateNumOps": 3000,
+				"DataFileIoRateAvgTime": 0,
+				"ReadIoRateNumOps": 2000,
+				"ReadIoRateAvgTime": 2.5,
+				"WriteIoRateNumOps": 1000,
+				"WriteIoRateAvgTime": 1.25,
+				"FileIoErrorRateNumOps": 3,
+				"FileIoErrorRateAvgTime": 12.5
+			}]}`,
+			want: map[string]float64{
+				`datanode_volume_io_total{disk="/data/1/dfs/dn",op="metadata_operation"}`:            120,
+				`datanode_volume_io_avg_time_seconds{disk="/data/1/dfs/dn",op="metadata_operation"}`: 0.5 / 1000,
+				`datanode_volume_io_total{disk="/data/1/dfs/dn",op="data_file_io"}`:                  3000,
+				`datanode_volume_io_avg_time_seconds{disk="/data/1/dfs/dn",op="data_file_io"}`:       0,
+				`datanode_volume_io_total{disk="/data/1/dfs/dn",op="read_io"}`:                       2000,
+				`datanode_volume_io_avg_time_seconds{disk="/data/1/dfs/dn",op="read_io"}`:            2.5 / 1000,
+				`datanode_volume_io_total{disk="/data/1/dfs/dn",op="write_io"}`:                      1000,
+				`datanode_volume_io_avg_time_seconds{disk="/data/1/dfs/dn",op="write_io"}`:           1.25 / 1000,
+				`datanode_volume_file_io_errors_total{disk="/data/1/dfs/dn"}`:                        3,
+			},
+		},
+		{
+			name: "percentiles",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeVolume-/data/2/dfs/dn",
+				"ReadIoLatency60sNumOps": 600,
+				"ReadIoLatency60s50thPercentileLatency": 2,
+				"ReadIoLatency60s99thPercentileLatency": 40,
+				"SyncIoLatency3600s75thPercentileLatency": 8
+			}]}`,
+			want: map[string]float64{
+				`datanode_volume_io_latency_interval_ops{disk="/data/2/dfs/dn",interval="60s",op="read_io"}`:              600,
+				`datanode_volume_io_latency_seconds{disk="/data/2/dfs/dn",interval="60s",op="read_io",quantile="0.5"}`:    2.0 / 1000,
+				`datanode_volume_io_latency_seconds{disk="/data/2/dfs/dn",interval="60s",op="read_io",quantile="0.99"}`:   40.0 / 1000,
+				`datanode_volume_io_latency_seconds{disk="/data/2/dfs/dn",interval="3600s",op="sync_io",quantile="0.75"}`: 8.0 / 1000,
+			},
+		},
+		{
+			name: "empty bean",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeVolume-/data/3/dfs/dn"
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "values of another shape",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeVolume-/data/4/dfs/dn",
+				"ReadIoRateNumOps": "many",
+				"ReadIoRateAvgTime": {},
+				"WriteIoLatency60s99thPercentileLatency": [40],
+				"WriteIoLatency60sNumOps": null,
+				"FileIoErrorRateNumOps": "{\"errors\":3}",
+				"SyncIoRateNumOps": 7
+			}]}`,
+			want: map[string]float64{
+				`datanode_volume_io_total{disk="/data/4/dfs/dn",op="sync_io"}`: 7,
+			},
+		},
+		{
+			name: "unknown attributes",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeVolume-/data/5/dfs/dn",
+				"TrimIoRateNumOps": 1,
+				"ReadIoLatencysNumOps": 2,
+				"ReadIoLatency60sMedianLatency": 3
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "other bean",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=DataNode,name=DataNodeActivity-dn1.example.com-9866",
+				"ReadIoRateNumOps": 2000
+			}]}`,
+			want: map[string]float64{},
+		},
+	} {
+		if got := collect(t, volumes{}, tt.jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -4,8 +4,10 @@ go 1.20
 
 require (
 	github.com/prometheus/client_golang v0.8.0
+	github.com/prometheus/client_model v0.0.0-20150212101744-fa8ad6fec335
 	github.com/prometheus/log v0.0.0-20151026012452-9a3136781e1f
 	gopkg.in/jcmturner/gokrb5.v7 v7.5.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -15,7 +17,6 @@ require (
 	github.com/hashicorp/go-uuid v1.0.3 // indirect
 	github.com/jcmturner/gofork v1.7.6 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
-	github.com/prometheus/client_model v0.0.0-20150212101744-fa8ad6fec335 // indirect
 	github.com/prometheus/common v0.0.0-20170108231212-dd2f054febf4 // indirect
 	github.com/prometheus/procfs v0.0.0-20161206222141-fcdb11ccb438 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
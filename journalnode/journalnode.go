@@ -0,0 +1,33 @@
+package journalnode
+
+import (
+	_ "embed"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+const namespace = "journalnode"
+
+// defaultRules are the built-in rules mapping the beans to metrics.
+//
+//go:embed rules.yaml
+var defaultRules []byte
+
+// collectors send the metrics of the beans that the rules cannot express.
+var collectors = []lib.BeanCollector{lib.NewRPCCollector(namespace), lib.NewJVMCollector(namespace)}
+
+// Role is the JournalNode role of the exporter.
+var Role = &lib.Role{
+	Name:          "journalnode",
+	Title:         "JournalNode",
+	Service:       "JournalNode",
+	Namespace:     namespace,
+	ListenAddress: ":9071",
+	URL:           "http://localhost:8480",
+	Path:          "/jmx",
+	LegacyURLFlag: "journalnode.jmx.url",
+	Rules:         defaultRules,
+	NewExporter: func(client *lib.JmxClient, rules *lib.Rules) lib.ContextCollector {
+		return lib.NewJmxExporter(client, rules, collectors...)
+	},
+}
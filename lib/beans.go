@@ -0,0 +1,317 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ParseBeans returns the beans of a /jmx response:
+// {"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem", ...}, {"name":"java.lang:type=MemoryPool,name=Code Cache", ...}, ...]}
+func ParseBeans(data []byte) ([]*Bean, error) {
+	var f struct {
+		Beans []map[string]interface{} `json:"beans"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid JMX response: %w", err)
+	}
+	if f.Beans == nil {
+		return nil, fmt.Errorf("invalid JMX response: no beans")
+	}
+
+	beans := make([]*Bean, 0, len(f.Beans))
+	for _, attrs := range f.Beans {
+		if attrs == nil {
+			continue
+		}
+		name, _ := attrs["name"].(string)
+		beans = append(beans, NewBean(name, attrs))
+	}
+	return beans, nil
+}
+
+// Bean gives typed access to the attributes of a JMX bean, or of any other
+// JSON object such as the clusterMetrics of the ResourceManager REST API.
+//
+// An attribute that is missing, null or of an unexpected type is reported to
+// the Scrape parsing the bean, and the accessor returns false so the caller
+// skips just the metric of that attribute.
+type Bean struct {
+	name   string
+	path   string // of a composite attribute, e.g. "HeapMemoryUsage."
+	attrs  map[string]interface{}
+	scrape *Scrape
+}
+
+// NewBean returns the bean with the attributes.
+func NewBean(name string, attrs map[string]interface{}) *Bean {
+	return &Bean{name: name, attrs: attrs}
+}
+
+// Name returns the name of the bean, e.g. "Hadoop:service=NameNode,name=FSNamesystem".
+func (b *Bean) Name() string {
+	return b.name
+}
+
+// ModelerType returns the modelerType of the bean, or "" if it has none.
+func (b *Bean) ModelerType() string {
+	t, _ := b.attrs["modelerType"].(string)
+	return t
+}
+
+// Float returns the attribute as a number. Numbers held in strings, such as
+// "12" or "NaN", and booleans (as 1 or 0) are converted.
+func (b *Bean) Float(attr string) (float64, bool) {
+	v, ok := b.value(attr)
+	if !ok {
+		return 0, false
+	}
+
+	f, ok := number(v)
+	if !ok {
+		if s, isString := v.(string); isString {
+			b.report(attr, "not a number: %q", s)
+		} else {
+			b.report(attr, "not a number: %T", v)
+		}
+	}
+	return f, ok
+}
+
+// String returns the attribute as a string. Numbers are formatted the way
+// they appear in the JSON.
+func (b *Bean) String(attr string) (string, bool) {
+	v, ok := b.value(attr)
+	if !ok {
+		return "", false
+	}
+
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+
+	b.report(attr, "not a string: %T", v)
+	return "", false
+}
+
+// Floats returns an array attribute, such as the QueueSizes of FairCallQueue,
+// as numbers.
+func (b *Bean) Floats(attr string) ([]float64, bool) {
+	v, ok := b.value(attr)
+	if !ok {
+		return nil, false
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		b.report(attr, "not an array: %T", v)
+		return nil, false
+	}
+	floats := make([]float64, len(items))
+	for i, item := range items {
+		if floats[i], ok = number(item); !ok {
+			b.report(attr, "not an array of numbers: %T at %d", item, i)
+			return nil, false
+		}
+	}
+	return floats, true
+}
+
+// Strings returns an array attribute of strings, such as the ReportingNodes
+// of a SlowPeersReport.
+func (b *Bean) Strings(attr string) ([]string, bool) {
+	v, ok := b.value(attr)
+	if !ok {
+		return nil, false
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		b.report(attr, "not an array: %T", v)
+		return nil, false
+	}
+	strs := make([]string, len(items))
+	for i, item := range items {
+		if strs[i], ok = item.(string); !ok {
+			b.report(attr, "not an array of strings: %T at %d", item, i)
+			return nil, false
+		}
+	}
+	return strs, true
+}
+
+// Object returns a composite attribute, such as the HeapMemoryUsage of
+// java.lang:type=Memory, as a bean of its own.
+func (b *Bean) Object(attr string) (*Bean, bool) {
+	v, ok := b.value(attr)
+	if !ok {
+		return nil, false
+	}
+
+	attrs, ok := v.(map[string]interface{})
+	if !ok {
+		b.report(attr, "not an object: %T", v)
+		return nil, false
+	}
+
+	return &Bean{name: b.name, path: b.path + attr + ".", attrs: attrs, scrape: b.scrape}, true
+}
+
+// Objects returns an array attribute of objects, such as the StorageTypeStats
+// of BlockStats, as beans of their own. JMX renders a Java map as such an
+// array of {"key":...,"value":...} objects.
+func (b *Bean) Objects(attr string) ([]*Bean, bool) {
+	v, ok := b.value(attr)
+	if !ok {
+		return nil, false
+	}
+	return b.objects(attr, v)
+}
+
+// JSON returns a string attribute holding a JSON object, such as the
+// LiveNodes of NameNodeInfo, as a bean of its own.
+func (b *Bean) JSON(attr string) (*Bean, bool) {
+	s, ok := b.String(attr)
+	if !ok {
+		return nil, false
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &attrs); err != nil || attrs == nil {
+		b.report(attr, "not a JSON object: %q", s)
+		return nil, false
+	}
+
+	return &Bean{name: b.name, path: b.path + attr + ".", attrs: attrs, scrape: b.scrape}, true
+}
+
+// JSONObjects returns a string attribute holding a JSON array of objects,
+// such as the SlowPeersReport of NameNodeInfo, as beans of their own.
+func (b *Bean) JSONObjects(attr string) ([]*Bean, bool) {
+	s, ok := b.String(attr)
+	if !ok {
+		return nil, false
+	}
+
+	var items []interface{}
+	if err := json.Unmarshal([]byte(s), &items); err != nil {
+		b.report(attr, "not a JSON array: %q", s)
+		return nil, false
+	}
+	return b.objects(attr, items)
+}
+
+// Has tells whether the bean has the attribute, without reporting it when it
+// is missing.
+func (b *Bean) Has(attr string) bool {
+	holder, field := b.field(attr)
+	return holder.attrs[field] != nil
+}
+
+// Keys returns the names of the attributes of the bean in sorted order.
+func (b *Bean) Keys() []string {
+	keys := make([]string, 0, len(b.attrs))
+	for key := range b.attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// field returns the bean holding the attribute and the name of the
+// attribute in it, following composite attributes such as HeapMemoryUsage.used.
+// Attribute names may contain dots themselves, e.g. tag.port.
+func (b *Bean) field(attr string) (*Bean, string) {
+	if _, ok := b.attrs[attr]; ok {
+		return b, attr
+	}
+	for i := 0; i < len(attr); i++ {
+		if attr[i] != '.' {
+			continue
+		}
+		if attrs, ok := b.attrs[attr[:i]].(map[string]interface{}); ok {
+			child := &Bean{name: b.name, path: b.path + attr[:i+1], attrs: attrs, scrape: b.scrape}
+			return child.field(attr[i+1:])
+		}
+	}
+	return b, attr
+}
+
+// flatten returns the names of all attributes, those of composite attributes
+// joined with a dot, in sorted order.
+func (b *Bean) flatten() []string {
+	var names []string
+	for name, v := range b.attrs {
+		if attrs, ok := v.(map[string]interface{}); ok {
+			for _, child := range (&Bean{attrs: attrs}).flatten() {
+				names = append(names, name+"."+child)
+			}
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// objects returns the objects of the array value of the attribute as beans.
+func (b *Bean) objects(attr string, v interface{}) ([]*Bean, bool) {
+	items, ok := v.([]interface{})
+	if !ok {
+		b.report(attr, "not an array: %T", v)
+		return nil, false
+	}
+	beans := make([]*Bean, len(items))
+	for i, item := range items {
+		attrs, ok := item.(map[string]interface{})
+		if !ok {
+			b.report(attr, "not an array of objects: %T at %d", item, i)
+			return nil, false
+		}
+		beans[i] = &Bean{name: b.name, path: fmt.Sprintf("%s%s[%d].", b.path, attr, i), attrs: attrs, scrape: b.scrape}
+	}
+	return beans, true
+}
+
+func (b *Bean) value(attr string) (interface{}, bool) {
+	v, ok := b.attrs[attr]
+	if !ok {
+		b.report(attr, "missing")
+		return nil, false
+	}
+	if v == nil {
+		b.report(attr, "null")
+		return nil, false
+	}
+	return v, true
+}
+
+func (b *Bean) report(attr string, format string, args ...interface{}) {
+	if b.scrape != nil {
+		b.scrape.attributeError(b.name, b.path+attr, fmt.Sprintf(format, args...))
+	}
+}
+
+// number converts a JSON value to a number. Numbers held in strings, such as
+// "12" or "NaN", and booleans (as 1 or 0) are converted.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
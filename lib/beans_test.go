@@ -0,0 +1,452 @@
+package lib
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// testScrape returns a scrape counting its errors apart from the exporter.
+func testScrape() *Scrape {
+	return &Scrape{target: "test", start: time.Now(), metrics: newScrapeMetrics(&attributeLog{})}
+}
+
+// attributeErrorCount returns how many errors of the attribute of the bean the
+// scrape counted.
+func attributeErrorCount(t *testing.T, s *Scrape, bean, attr string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := s.metrics.attributeErrors.WithLabelValues(s.target, bean, attr).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// parseBean returns the only bean of the /jmx response, parsed by the scrape.
+func parseBean(t *testing.T, s *Scrape, jmx string) *Bean {
+	t.Helper()
+	beans, err := ParseBeans([]byte(jmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beans) != 1 {
+		t.Fatalf("got %d beans, want 1", len(beans))
+	}
+	beans[0].scrape = s
+	return beans[0]
+}
+
+func TestParseBeans(t *testing.T) {
+	for _, tt := range []struct {
+		jmx     string
+		names   []string
+		wantErr bool
+	}{
+		{jmx: `{"beans":[]}`, names: []string{}},
+		{jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem"},null,{"name":"java.lang:type=Memory"}]}`, names: []string{"Hadoop:service=NameNode,name=FSNamesystem", "java.lang:type=Memory"}},
+		{jmx: `{"beans":[{"modelerType":"FSNamesystem"}]}`, names: []string{""}},
+		{jmx: `{}`, wantErr: true},
+		{jmx: `<html>`, wantErr: true},
+	} {
+		beans, err := ParseBeans([]byte(tt.jmx))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBeans(%s) error = %v, want error %t", tt.jmx, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		names := []string{}
+		for _, b := range beans {
+			names = append(names, b.Name())
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("ParseBeans(%s) = %q, want %q", tt.jmx, names, tt.names)
+		}
+	}
+}
+
+func TestBeanField(t *testing.T) {
+	bean := parseBean(t, nil, `{"beans":[{
+		"name": "java.lang:type=Memory",
+		"tag.port": "8020",
+		"HeapMemoryUsage": {"used": 100, "max": 200},
+		"tag": {"Context": "dfs"}
+	}]}`)
+
+	for _, tt := range []struct {
+		attr  string
+		path  string
+		field string
+	}{
+		// A dotted name of the bean itself wins over a composite attribute.
+		{attr: "tag.port", path: "", field: "tag.port"},
+		{attr: "tag.Context", path: "tag.", field: "Context"},
+		{attr: "HeapMemoryUsage.used", path: "HeapMemoryUsage.", field: "used"},
+		{attr: "HeapMemoryUsage.committed", path: "HeapMemoryUsage.", field: "committed"},
+		{attr: "NonHeapMemoryUsage.used", path: "", field: "NonHeapMemoryUsage.used"},
+		{attr: "HeapMemoryUsage", path: "", field: "HeapMemoryUsage"},
+	} {
+		holder, field := bean.field(tt.attr)
+		if holder.path != tt.path || field != tt.field {
+			t.Errorf("field(%q) = %q, %q, want %q, %q", tt.attr, holder.path, field, tt.path, tt.field)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		want float64
+		ok   bool
+	}{
+		{v: 12.5, want: 12.5, ok: true},
+		{v: "12", want: 12, ok: true},
+		{v: "-1.5e3", want: -1500, ok: true},
+		{v: true, want: 1, ok: true},
+		{v: false, want: 0, ok: true},
+		{v: "active", ok: false},
+		{v: "", ok: false},
+		{v: nil, ok: false},
+		{v: []interface{}{1.0}, ok: false},
+		{v: map[string]interface{}{"used": 1.0}, ok: false},
+	} {
+		got, ok := number(tt.v)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("number(%#v) = %v, %t, want %v, %t", tt.v, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if got, ok := number("NaN"); !ok || !math.IsNaN(got) {
+		t.Errorf(`number("NaN") = %v, %t, want NaN, true`, got, ok)
+	}
+}
+
+func TestBeanFloat(t *testing.T) {
+	const name = "Hadoop:service=NameNode,name=FSNamesystem"
+	s := testScrape()
+	bean := parseBean(t, s, `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=FSNamesystem",
+		"CapacityTotal": 1024,
+		"BlocksTotal": "12",
+		"tag.IsOutOfSync": true,
+		"tag.HAState": "active",
+		"MissingBlocks": null,
+		"NameDirStatuses": {"active": {}}
+	}]}`)
+
+	for _, tt := range []struct {
+		attr   string
+		want   float64
+		ok     bool
+		errors float64
+	}{
+		{attr: "CapacityTotal", want: 1024, ok: true},
+		{attr: "BlocksTotal", want: 12, ok: true},
+		{attr: "tag.IsOutOfSync", want: 1, ok: true},
+		{attr: "tag.HAState", errors: 1},
+		{attr: "MissingBlocks", errors: 1},
+		{attr: "CorruptBlocks", errors: 1},
+		{attr: "NameDirStatuses", errors: 1},
+	} {
+		got, ok := bean.Float(tt.attr)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Float(%q) = %v, %t, want %v, %t", tt.attr, got, ok, tt.want, tt.ok)
+		}
+		if n := attributeErrorCount(t, s, name, tt.attr); n != tt.errors {
+			t.Errorf("Float(%q) counted %v attribute errors, want %v", tt.attr, n, tt.errors)
+		}
+	}
+}
+
+func TestBeanString(t *testing.T) {
+	bean := parseBean(t, testScrape(), `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+		"Version": "3.3.6, r1be78238728da9266a4f88195058f08fd012bf9c",
+		"tag.port": 8020,
+		"Safemode": "",
+		"IsOutOfSync": false,
+		"LiveNodes": {}
+	}]}`)
+
+	for _, tt := range []struct {
+		attr string
+		want string
+		ok   bool
+	}{
+		{attr: "Version", want: "3.3.6, r1be78238728da9266a4f88195058f08fd012bf9c", ok: true},
+		{attr: "tag.port", want: "8020", ok: true},
+		{attr: "Safemode", want: "", ok: true},
+		{attr: "IsOutOfSync", want: "false", ok: true},
+		{attr: "LiveNodes", ok: false},
+		{attr: "ClusterId", ok: false},
+	} {
+		got, ok := bean.String(tt.attr)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("String(%q) = %q, %t, want %q, %t", tt.attr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBeanArrays(t *testing.T) {
+	bean := parseBean(t, testScrape(), `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=FairCallQueue",
+		"QueueSizes": [3, "4", 0],
+		"ReportingNodes": ["dn1:9866", "dn3:9866"],
+		"Mixed": ["dn1:9866", 3],
+		"Scalar": 3
+	}]}`)
+
+	for _, tt := range []struct {
+		attr    string
+		floats  []float64
+		strings []string
+	}{
+		{attr: "QueueSizes", floats: []float64{3, 4, 0}},
+		{attr: "ReportingNodes", strings: []string{"dn1:9866", "dn3:9866"}},
+		{attr: "Mixed"},
+		{attr: "Scalar"},
+		{attr: "Missing"},
+	} {
+		if got, ok := bean.Floats(tt.attr); ok != (tt.floats != nil) || !reflect.DeepEqual(got, tt.floats) {
+			t.Errorf("Floats(%q) = %v, %t, want %v", tt.attr, got, ok, tt.floats)
+		}
+		if got, ok := bean.Strings(tt.attr); ok != (tt.strings != nil) || !reflect.DeepEqual(got, tt.strings) {
+			t.Errorf("Strings(%q) = %q, %t, want %q", tt.attr, got, ok, tt.strings)
+		}
+	}
+}
+
+func TestBeanObjects(t *testing.T) {
+	const name = "Hadoop:service=NameNode,name=BlockStats"
+	s := testScrape()
+	bean := parseBean(t, s, `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=BlockStats",
+		"StorageTypeStats": [{"key": "DISK", "value": {"nodesInService": 3}}, {"key": "SSD", "value": {"nodesInService": 1}}],
+		"Empty": [],
+		"Numbers": [1, 2],
+		"Scalar": "DISK"
+	}]}`)
+
+	for _, tt := range []struct {
+		attr  string
+		keys  []string
+		paths []string
+	}{
+		{attr: "StorageTypeStats", keys: []string{"DISK", "SSD"}, paths: []string{"StorageTypeStats[0].", "StorageTypeStats[1]."}},
+		{attr: "Empty", keys: []string{}, paths: []string{}},
+		{attr: "Numbers"},
+		{attr: "Scalar"},
+		{attr: "Missing"},
+	} {
+		objects, ok := bean.Objects(tt.attr)
+		if ok != (tt.keys != nil) {
+			t.Errorf("Objects(%q) ok = %t, want %t", tt.attr, ok, tt.keys != nil)
+			continue
+		}
+		if !ok {
+			if n := attributeErrorCount(t, s, name, tt.attr); n != 1 {
+				t.Errorf("Objects(%q) counted %v attribute errors, want 1", tt.attr, n)
+			}
+			continue
+		}
+		keys, paths := []string{}, []string{}
+		for _, o := range objects {
+			key, _ := o.String("key")
+			keys = append(keys, key)
+			paths = append(paths, o.path)
+		}
+		if !reflect.DeepEqual(keys, tt.keys) || !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("Objects(%q) = %q at %q, want %q at %q", tt.attr, keys, paths, tt.keys, tt.paths)
+		}
+	}
+
+	// An attribute missing from an object of the array is reported with its
+	// path.
+	stats, _ := bean.Objects("StorageTypeStats")
+	ssd, ok := stats[1].Object("value")
+	if !ok {
+		t.Fatal(`Object("value") of the SSD stats is not ok`)
+	}
+	if _, ok := ssd.Float("blockPoolUsed"); ok {
+		t.Error(`Float("blockPoolUsed") of the SSD stats is ok, want missing`)
+	}
+	if n := attributeErrorCount(t, s, name, "StorageTypeStats[1].value.blockPoolUsed"); n != 1 {
+		t.Errorf("counted %v errors of StorageTypeStats[1].value.blockPoolUsed, want 1", n)
+	}
+}
+
+func TestBeanJSONObjects(t *testing.T) {
+	const name = "Hadoop:service=NameNode,name=NameNodeInfo"
+	s := testScrape()
+	bean := parseBean(t, s, `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+		"SlowPeersReport": "[{\"SlowNode\":\"dn2:9866\",\"ReportingNodes\":[\"dn1:9866\"]},{\"SlowNode\":\"dn3:9866\"}]",
+		"SlowDisksReport": null,
+		"EmptyReport": "[]",
+		"ObjectReport": "{\"SlowNode\":\"dn2:9866\"}",
+		"NumbersReport": "[1, 2]",
+		"BadReport": "[{",
+		"EmptyString": ""
+	}]}`)
+
+	for _, tt := range []struct {
+		attr  string
+		nodes []string
+		paths []string
+	}{
+		{attr: "SlowPeersReport", nodes: []string{"dn2:9866", "dn3:9866"}, paths: []string{"SlowPeersReport[0].", "SlowPeersReport[1]."}},
+		{attr: "EmptyReport", nodes: []string{}, paths: []string{}},
+		{attr: "SlowDisksReport"},
+		{attr: "ObjectReport"},
+		{attr: "NumbersReport"},
+		{attr: "BadReport"},
+		{attr: "EmptyString"},
+	} {
+		objects, ok := bean.JSONObjects(tt.attr)
+		if ok != (tt.nodes != nil) {
+			t.Errorf("JSONObjects(%q) ok = %t, want %t", tt.attr, ok, tt.nodes != nil)
+			continue
+		}
+		if !ok {
+			if n := attributeErrorCount(t, s, name, tt.attr); n != 1 {
+				t.Errorf("JSONObjects(%q) counted %v attribute errors, want 1", tt.attr, n)
+			}
+			continue
+		}
+		nodes, paths := []string{}, []string{}
+		for _, o := range objects {
+			node, _ := o.String("SlowNode")
+			nodes = append(nodes, node)
+			paths = append(paths, o.path)
+		}
+		if !reflect.DeepEqual(nodes, tt.nodes) || !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("JSONObjects(%q) = %q at %q, want %q at %q", tt.attr, nodes, paths, tt.nodes, tt.paths)
+		}
+	}
+
+	// An attribute missing from an object of the array is reported with its
+	// path.
+	reports, _ := bean.JSONObjects("SlowPeersReport")
+	if _, ok := reports[1].Strings("ReportingNodes"); ok {
+		t.Error(`Strings("ReportingNodes") of the second slow peer is ok, want missing`)
+	}
+	if n := attributeErrorCount(t, s, name, "SlowPeersReport[1].ReportingNodes"); n != 1 {
+		t.Errorf("counted %v errors of SlowPeersReport[1].ReportingNodes, want 1", n)
+	}
+}
+
+func TestBeanObject(t *testing.T) {
+	const name = "java.lang:type=Memory"
+	s := testScrape()
+	bean := parseBean(t, s, `{"beans":[{
+		"name": "java.lang:type=Memory",
+		"HeapMemoryUsage": {"used": 100, "max": null},
+		"ObjectPendingFinalizationCount": 0
+	}]}`)
+
+	heap, ok := bean.Object("HeapMemoryUsage")
+	if !ok {
+		t.Fatal(`Object("HeapMemoryUsage") is not ok`)
+	}
+	if used, ok := heap.Float("used"); !ok || used != 100 {
+		t.Errorf(`Float("used") of HeapMemoryUsage = %v, %t, want 100, true`, used, ok)
+	}
+	// An attribute of a composite attribute is reported with its path.
+	if _, ok := heap.Float("max"); ok {
+		t.Error(`Float("max") of HeapMemoryUsage is ok, want null`)
+	}
+	if n := attributeErrorCount(t, s, name, "HeapMemoryUsage.max"); n != 1 {
+		t.Errorf("counted %v errors of HeapMemoryUsage.max, want 1", n)
+	}
+
+	for _, attr := range []string{"ObjectPendingFinalizationCount", "NonHeapMemoryUsage"} {
+		if _, ok := bean.Object(attr); ok {
+			t.Errorf("Object(%q) is ok, want not an object", attr)
+		}
+		if n := attributeErrorCount(t, s, name, attr); n != 1 {
+			t.Errorf("Object(%q) counted %v attribute errors, want 1", attr, n)
+		}
+	}
+}
+
+func TestBeanJSON(t *testing.T) {
+	bean := parseBean(t, testScrape(), `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+		"LiveNodes": "{\"dn1:9866\":{\"usedSpace\":100}}",
+		"DeadNodes": "{}",
+		"NameJournalStatus": "[]",
+		"Version": "3.3.6"
+	}]}`)
+
+	nodes, ok := bean.JSON("LiveNodes")
+	if !ok {
+		t.Fatal(`JSON("LiveNodes") is not ok`)
+	}
+	node, ok := nodes.Object("dn1:9866")
+	if !ok {
+		t.Fatal(`Object("dn1:9866") of LiveNodes is not ok`)
+	}
+	if node.path != "LiveNodes.dn1:9866." {
+		t.Errorf("path of dn1:9866 = %q, want %q", node.path, "LiveNodes.dn1:9866.")
+	}
+	if used, ok := node.Float("usedSpace"); !ok || used != 100 {
+		t.Errorf(`Float("usedSpace") of dn1:9866 = %v, %t, want 100, true`, used, ok)
+	}
+	for _, tt := range []struct {
+		attr string
+		ok   bool
+	}{
+		{attr: "DeadNodes", ok: true},
+		{attr: "NameJournalStatus", ok: false},
+		{attr: "Version", ok: false},
+	} {
+		if _, ok := bean.JSON(tt.attr); ok != tt.ok {
+			t.Errorf("JSON(%q) ok = %t, want %t", tt.attr, ok, tt.ok)
+		}
+	}
+}
+
+func TestBeanHas(t *testing.T) {
+	s := testScrape()
+	bean := parseBean(t, s, `{"beans":[{
+		"name": "java.lang:type=Memory",
+		"HeapMemoryUsage": {"used": 100, "max": null},
+		"ObjectPendingFinalizationCount": null
+	}]}`)
+
+	for _, tt := range []struct {
+		attr string
+		want bool
+	}{
+		{attr: "HeapMemoryUsage", want: true},
+		{attr: "HeapMemoryUsage.used", want: true},
+		{attr: "HeapMemoryUsage.max", want: false},
+		{attr: "ObjectPendingFinalizationCount", want: false},
+		{attr: "Verbose", want: false},
+	} {
+		if got := bean.Has(tt.attr); got != tt.want {
+			t.Errorf("Has(%q) = %t, want %t", tt.attr, got, tt.want)
+		}
+		if n := attributeErrorCount(t, s, "java.lang:type=Memory", tt.attr); n != 0 {
+			t.Errorf("Has(%q) counted %v attribute errors, want 0", tt.attr, n)
+		}
+	}
+}
+
+func TestBeanFlatten(t *testing.T) {
+	bean := parseBean(t, nil, `{"beans":[{
+		"name": "java.lang:type=Memory",
+		"modelerType": "sun.management.MemoryImpl",
+		"HeapMemoryUsage": {"used": 100, "max": 200},
+		"Verbose": false
+	}]}`)
+
+	want := []string{"HeapMemoryUsage.max", "HeapMemoryUsage.used", "Verbose", "modelerType", "name"}
+	if got := bean.flatten(); !reflect.DeepEqual(got, want) {
+		t.Errorf("flatten() = %q, want %q", got, want)
+	}
+}
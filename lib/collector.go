@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BeanCollector sends the metrics of beans that rules cannot express, such as
+// those parsed from JSON held in a string attribute.
+type BeanCollector interface {
+	CollectBean(e *Emitter, bean *Bean)
+}
+
+// DerivedCollector is a BeanCollector that also sends metrics derived from
+// several beans of a scrape, such as a state read from one bean or, failing
+// that, from another.
+type DerivedCollector interface {
+	BeanCollector
+	CollectDerived(e *Emitter, beans map[string]*Bean)
+}
+
+// CollectBeans sends the metrics of the beans through the collectors and e,
+// which adds its extra labels to every metric as Rules.CollectLabels does.
+func CollectBeans(e *Emitter, scrape *Scrape, beans []*Bean, collectors []BeanCollector) {
+	if len(collectors) == 0 {
+		return
+	}
+	for _, bean := range beans {
+		scrape.Bean(bean, func() {
+			for _, c := range collectors {
+				c.CollectBean(e, bean)
+			}
+		})
+	}
+
+	byName := make(map[string]*Bean, len(beans))
+	for _, bean := range beans {
+		byName[bean.Name()] = bean
+	}
+	for _, c := range collectors {
+		if d, ok := c.(DerivedCollector); ok {
+			scrape.Derived(func() { d.CollectDerived(e, byName) })
+		}
+	}
+}
+
+// MetricDesc describes a metric sent by a BeanCollector. Its descriptor gains
+// the extra labels of the collection, such as nn_id, when the metric is sent.
+type MetricDesc struct {
+	fqName string
+	help   string
+	labels []string
+
+	mu    sync.Mutex
+	descs map[string]*prometheus.Desc // by extra label names
+}
+
+// NewMetricDesc returns the description of the namespace_subsystem_name metric.
+func NewMetricDesc(namespace, subsystem, name, help string, labels ...string) *MetricDesc {
+	return &MetricDesc{
+		fqName: prometheus.BuildFQName(namespace, subsystem, name),
+		help:   help,
+		labels: labels,
+		descs:  map[string]*prometheus.Desc{},
+	}
+}
+
+func (d *MetricDesc) desc(extra []string) *prometheus.Desc {
+	key := strings.Join(extra, "\x00")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if desc, ok := d.descs[key]; ok {
+		return desc
+	}
+	labels := append(d.labels[:len(d.labels):len(d.labels)], extra...)
+	desc := prometheus.NewDesc(d.fqName, d.help, labels, nil)
+	d.descs[key] = desc
+	return desc
+}
+
+// Emitter sends the metrics of a BeanCollector, adding the extra labels of
+// the collection.
+type Emitter struct {
+	ch chan<- prometheus.Metric
+
+	labelNames  []string
+	labelValues []string
+}
+
+// NewEmitter returns an Emitter sending to ch, adding the extra labels to
+// every metric, e.g. the nn_id of a NameNode of a nameservice.
+func NewEmitter(ch chan<- prometheus.Metric, labelNames, labelValues []string) *Emitter {
+	return &Emitter{ch: ch, labelNames: labelNames, labelValues: labelValues}
+}
+
+// Gauge sends a gauge of d.
+func (e *Emitter) Gauge(d *MetricDesc, value float64, labels ...string) {
+	e.send(d, prometheus.GaugeValue, value, labels)
+}
+
+// Counter sends a counter of d.
+func (e *Emitter) Counter(d *MetricDesc, value float64, labels ...string) {
+	e.send(d, prometheus.CounterValue, value, labels)
+}
+
+// BeanGauge sends the attribute of the bean as a gauge of d, unless the
+// attribute is not a number.
+func (e *Emitter) BeanGauge(d *MetricDesc, bean *Bean, attr string, labels ...string) {
+	if v, ok := bean.Float(attr); ok {
+		e.Gauge(d, v, labels...)
+	}
+}
+
+// BeanCounter sends the attribute of the bean as a counter of d, unless the
+// attribute is not a number.
+func (e *Emitter) BeanCounter(d *MetricDesc, bean *Bean, attr string, labels ...string) {
+	if v, ok := bean.Float(attr); ok {
+		e.Counter(d, v, labels...)
+	}
+}
+
+func (e *Emitter) send(d *MetricDesc, t prometheus.ValueType, value float64, labels []string) {
+	values := append(labels[:len(labels):len(labels)], e.labelValues...)
+	e.ch <- prometheus.MustNewConstMetric(d.desc(e.labelNames), t, value, values...)
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/jcmturner/gokrb5.v7/krberror"
+)
+
+var (
+	// ErrKrb5Config is returned when krb5.conf cannot be loaded or the principal is malformed.
+	ErrKrb5Config = errors.New("invalid Kerberos configuration")
+	// ErrBadKeytab is returned when the keytab cannot be loaded.
+	ErrBadKeytab = errors.New("bad keytab")
+	// ErrKDCUnreachable is returned when no KDC of the realm answered.
+	ErrKDCUnreachable = errors.New("KDC unreachable")
+	// ErrKrb5Login is returned when the KDC refused the login or the ticket request.
+	ErrKrb5Login = errors.New("Kerberos login failed")
+	// ErrSPNEGORejected is returned when the server still answers 401 to a request carrying a SPNEGO token.
+	ErrSPNEGORejected = errors.New("SPNEGO authentication rejected")
+)
+
+// HTTPStatusError is returned when the server answers with a non-2xx status.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status from %s: %s", e.URL, e.Status)
+}
+
+// krb5Error wraps an error returned by gokrb5 in ErrKDCUnreachable or ErrKrb5Login.
+func krb5Error(err error, format string, a ...interface{}) error {
+	kind := ErrKrb5Login
+	var kerr krberror.Krberror
+	if errors.As(err, &kerr) && kerr.RootCause == krberror.NetworkingError {
+		kind = ErrKDCUnreachable
+	}
+	return fmt.Errorf("%w: %s: %w", kind, fmt.Sprintf(format, a...), err)
+}
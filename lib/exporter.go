@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// JmxExporter exports the beans of the /jmx endpoint of a daemon through
+// its rules and the collectors of its role.
+type JmxExporter struct {
+	client     *JmxClient
+	rules      *Rules
+	collectors []BeanCollector
+}
+
+// NewJmxExporter returns the exporter of the daemon served by client.
+func NewJmxExporter(client *JmxClient, rules *Rules, collectors ...BeanCollector) *JmxExporter {
+	return &JmxExporter{
+		client:     client,
+		rules:      rules,
+		collectors: collectors,
+	}
+}
+
+// Describe implements the prometheus.Collector interface. The metrics of the
+// rules depend on the beans of the daemon and are not described.
+func (e *JmxExporter) Describe(ch chan<- *prometheus.Desc) {
+	DescribeScrape(ch)
+}
+
+// CollectContext implements the ContextCollector interface.
+func (e *JmxExporter) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	scrape := BeginScrape(ctx, e.client.Target())
+	defer scrape.Done(ch)
+
+	data, err := e.client.Fetch(ctx)
+	if err != nil {
+		scrape.FailFetch(err)
+		return
+	}
+	beans, err := ParseBeans(data)
+	if err != nil {
+		scrape.Fail(StageParse, err)
+		return
+	}
+	e.rules.Collect(ch, scrape, beans)
+	CollectBeans(NewEmitter(ch, nil, nil), scrape, beans, e.collectors)
+}
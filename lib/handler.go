@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/log"
+)
+
+// scrapeTimeoutOffset is kept free of the Prometheus scrape timeout so the
+// exporter can still answer when the Hadoop daemon does not.
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// ContextCollector is a prometheus.Collector whose scrape is bound to the
+// context of the HTTP request asking for the metrics.
+type ContextCollector interface {
+	Describe(ch chan<- *prometheus.Desc)
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+type contextCollector struct {
+	ContextCollector
+	ctx context.Context
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(c.ctx, ch)
+}
+
+// MetricsHandler serves the metrics of the default registry together with
+// those of c. The scrape of c is cancelled when the request is, or when the
+// timeout announced by Prometheus in X-Prometheus-Scrape-Timeout-Seconds is
+// about to pass.
+func MetricsHandler(c ContextCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The collector goes first so the default registry already sees
+		// the outcome of this scrape, e.g. in the counters of ScrapeCollector.
+		serveCollector(w, r, c, prometheus.DefaultGatherer)
+	})
+}
+
+// serveCollector serves the metrics of c, bound to the context of the
+// request, followed by those of the gatherers.
+func serveCollector(w http.ResponseWriter, r *http.Request, c ContextCollector, gatherers ...prometheus.Gatherer) {
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(contextCollector{c, ctx})
+
+	promhttp.HandlerFor(append(prometheus.Gatherers{reg}, gatherers...), promhttp.HandlerOpts{
+		ErrorLog:      errorLogger{},
+		ErrorHandling: promhttp.ContinueOnError,
+	}).ServeHTTP(w, r)
+}
+
+// errorLogger passes errors of the promhttp handler to the exporter's log.
+type errorLogger struct{}
+
+func (errorLogger) Println(v ...interface{}) {
+	log.Errorln(v...)
+}
+
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return context.WithCancel(r.Context())
+	}
+
+	seconds, err := strconv.ParseFloat(v, 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > 2*scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+
+	return context.WithTimeout(r.Context(), timeout)
+}
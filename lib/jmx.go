@@ -0,0 +1,137 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// JmxOptions control how a JmxClient talks to a Hadoop daemon.
+type JmxOptions struct {
+	// Timeout bounds a fetch including retries. The Prometheus scrape
+	// timeout shortens it further when it is smaller.
+	Timeout time.Duration
+	// Retries is the number of extra attempts after a failed request.
+	Retries int
+	// Backoff is the wait before the first retry, doubled for every retry after.
+	Backoff time.Duration
+}
+
+// JmxOptionsFlags registers the JMX client flags shared by all exporters on
+// the default flag set. The returned JmxOptions is filled in by flag.Parse.
+func JmxOptionsFlags() *JmxOptions {
+	o := &JmxOptions{}
+	flag.DurationVar(&o.Timeout, "jmx.timeout", 10*time.Second, "Timeout of a scrape of the Hadoop daemon, including retries.")
+	flag.IntVar(&o.Retries, "jmx.retries", 2, "Number of retries of a failed request to the Hadoop daemon.")
+	flag.DurationVar(&o.Backoff, "jmx.retry-backoff", 200*time.Millisecond, "Wait before the first retry, doubled for every further retry.")
+	return o
+}
+
+// jmxTransport is shared by all clients so connections to a daemon are reused
+// between scrapes. It asks for gzip and transparently decompresses responses.
+var jmxTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConnsPerHost:   4,
+	IdleConnTimeout:       90 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+}
+
+// JmxClient fetches the JSON served by a Hadoop daemon, either from /jmx or
+// from a REST endpoint such as the ResourceManager's /ws/v1/cluster/metrics.
+type JmxClient struct {
+	URL  string
+	auth Krb5Auth
+	opts JmxOptions
+	http *http.Client
+}
+
+// NewJmxClient returns a client for the url.
+func NewJmxClient(url string, auth Krb5Auth, opts JmxOptions) *JmxClient {
+	return &JmxClient{
+		URL:  url,
+		auth: auth,
+		opts: opts,
+		http: &http.Client{Transport: jmxTransport},
+	}
+}
+
+// WithURL returns a client for another URL of the same daemon, with the same
+// authentication and options.
+func (c *JmxClient) WithURL(url string) *JmxClient {
+	return NewJmxClient(url, c.auth, c.opts)
+}
+
+// Target returns the host:port of the URL, used as target label.
+func (c *JmxClient) Target() string {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Host == "" {
+		return c.URL
+	}
+	return u.Host
+}
+
+// Fetch returns the body of the URL. Network errors and 5xx responses are
+// retried with backoff until the retries or the context run out.
+func (c *JmxClient) Fetch(ctx context.Context) ([]byte, error) {
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		body, err := c.fetch(ctx)
+		if err == nil || attempt >= c.opts.Retries || !retryable(err) {
+			return body, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *JmxClient) fetch(ctx context.Context) ([]byte, error) {
+	r, err := http.NewRequest("GET", c.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	r = r.WithContext(ctx)
+
+	if err := c.auth.Authorize(r); err != nil {
+		return nil, err
+	}
+
+	return do(c.http, r)
+}
+
+// retryable reports whether another attempt could succeed. Authentication and
+// configuration errors and 4xx responses will not go away by retrying.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	return !errors.Is(err, ErrKrb5Config) &&
+		!errors.Is(err, ErrBadKeytab) &&
+		!errors.Is(err, ErrKrb5Login) &&
+		!errors.Is(err, ErrSPNEGORejected)
+}
@@ -0,0 +1,199 @@
+package lib
+
+import "strings"
+
+// jvmThreadStates names the state label of the thread counts of JvmMetrics.
+var jvmThreadStates = map[string]string{
+	"ThreadsNew":          "new",
+	"ThreadsRunnable":     "runnable",
+	"ThreadsBlocked":      "blocked",
+	"ThreadsWaiting":      "waiting",
+	"ThreadsTimedWaiting": "timed_waiting",
+	"ThreadsTerminated":   "terminated",
+}
+
+// jvmLogLevels names the level label of the Log4j event counters of
+// JvmMetrics.
+var jvmLogLevels = map[string]string{
+	"LogFatal": "fatal",
+	"LogError": "error",
+	"LogWarn":  "warn",
+	"LogInfo":  "info",
+}
+
+// JVMCollector collects the beans of the JVM of a Hadoop daemon: the
+// java.lang platform beans, whatever its garbage collectors and memory pools,
+// and the JvmMetrics of Hadoop. The OperatingSystem and Runtime beans give
+// process_* series like those of the Prometheus client libraries.
+type JVMCollector struct {
+	memory               *MetricDesc
+	memoryPool           *MetricDesc
+	gcCollections        *MetricDesc
+	gcTime               *MetricDesc
+	threads              *MetricDesc
+	threadsState         *MetricDesc
+	threadsDaemon        *MetricDesc
+	threadsPeak          *MetricDesc
+	classesLoaded        *MetricDesc
+	classesLoadedTotal   *MetricDesc
+	classesUnloadedTotal *MetricDesc
+	gcWarnThreshold      *MetricDesc
+	gcInfoThreshold      *MetricDesc
+	gcExtraSleep         *MetricDesc
+	logEvents            *MetricDesc
+	info                 *MetricDesc
+
+	openFDs       *MetricDesc
+	maxFDs        *MetricDesc
+	cpuTime       *MetricDesc
+	cpuLoad       *MetricDesc
+	virtualMemory *MetricDesc
+	startTime     *MetricDesc
+	uptime        *MetricDesc
+	loadAverage   *MetricDesc
+	processors    *MetricDesc
+}
+
+// NewJVMCollector returns the collector of the JVM of a role, whose metrics
+// are named <namespace>_jvm_*, <namespace>_process_* and <namespace>_os_*.
+func NewJVMCollector(namespace string) *JVMCollector {
+	const subsystem = "jvm"
+	return &JVMCollector{
+		memory:               NewMetricDesc(namespace, subsystem, "memory_bytes", "Memory of the JVM by area and mode", "area", "mode"),
+		memoryPool:           NewMetricDesc(namespace, subsystem, "memory_pool_bytes", "Memory of the JVM memory pool by mode", "pool", "mode"),
+		gcCollections:        NewMetricDesc(namespace, subsystem, "gc_collections_total", "Total number of collections of the garbage collector", "gc"),
+		gcTime:               NewMetricDesc(namespace, subsystem, "gc_collection_seconds_total", "Total time spent in collections of the garbage collector", "gc"),
+		threads:              NewMetricDesc(namespace, subsystem, "threads_current", "Current number of live threads"),
+		threadsState:         NewMetricDesc(namespace, subsystem, "threads_state", "Current number of threads by state", "state"),
+		threadsDaemon:        NewMetricDesc(namespace, subsystem, "threads_daemon", "Current number of live daemon threads"),
+		threadsPeak:          NewMetricDesc(namespace, subsystem, "threads_peak", "Peak number of live threads"),
+		classesLoaded:        NewMetricDesc(namespace, subsystem, "classes_loaded", "Current number of loaded classes"),
+		classesLoadedTotal:   NewMetricDesc(namespace, subsystem, "classes_loaded_total", "Total number of classes loaded since the JVM started"),
+		classesUnloadedTotal: NewMetricDesc(namespace, subsystem, "classes_unloaded_total", "Total number of classes unloaded since the JVM started"),
+		gcWarnThreshold:      NewMetricDesc(namespace, subsystem, "gc_warn_threshold_exceeded_total", "Total number of JVM pauses longer than the warn threshold of the JvmPauseMonitor"),
+		gcInfoThreshold:      NewMetricDesc(namespace, subsystem, "gc_info_threshold_exceeded_total", "Total number of JVM pauses longer than the info threshold of the JvmPauseMonitor"),
+		gcExtraSleep:         NewMetricDesc(namespace, subsystem, "gc_extra_sleep_seconds_total", "Total time the JvmPauseMonitor slept longer than asked, i.e. the JVM was paused"),
+		logEvents:            NewMetricDesc(namespace, subsystem, "log_events_total", "Total number of Log4j events by level", "level"),
+		info:                 NewMetricDesc(namespace, subsystem, "info", "JVM version info", "vendor", "version"),
+
+		openFDs:       NewMetricDesc(namespace, "process", "open_fds", "Number of open file descriptors"),
+		maxFDs:        NewMetricDesc(namespace, "process", "max_fds", "Maximum number of open file descriptors"),
+		cpuTime:       NewMetricDesc(namespace, "process", "cpu_seconds_total", "Total user and system CPU time spent"),
+		cpuLoad:       NewMetricDesc(namespace, "process", "cpu_load", "Recent CPU usage of the process between 0 and 1, all CPUs of the host being 1"),
+		virtualMemory: NewMetricDesc(namespace, "process", "virtual_memory_committed_bytes", "Virtual memory guaranteed to be available to the process"),
+		startTime:     NewMetricDesc(namespace, "process", "start_time_seconds", "Start time of the process since unix epoch"),
+		uptime:        NewMetricDesc(namespace, "process", "uptime_seconds", "Time since the JVM started"),
+		loadAverage:   NewMetricDesc(namespace, "os", "system_load_average", "System load average of the host over the last minute"),
+		processors:    NewMetricDesc(namespace, "os", "available_processors", "Number of processors available to the JVM"),
+	}
+}
+
+// CollectBean implements the BeanCollector interface.
+func (c *JVMCollector) CollectBean(e *Emitter, bean *Bean) {
+	keys := objectNameKeys(bean.Name())
+	switch {
+	case strings.HasPrefix(bean.Name(), "java.lang:"):
+		switch keys["type"] {
+		case "Memory":
+			c.collectUsage(e, c.memory, bean, "HeapMemoryUsage", "heap")
+			c.collectUsage(e, c.memory, bean, "NonHeapMemoryUsage", "nonheap")
+		case "MemoryPool":
+			c.collectUsage(e, c.memoryPool, bean, "Usage", keys["name"])
+		case "GarbageCollector":
+			e.BeanCounter(c.gcCollections, bean, "CollectionCount", keys["name"])
+			if v, ok := bean.Float("CollectionTime"); ok {
+				e.Counter(c.gcTime, v/1000, keys["name"])
+			}
+		case "Threading":
+			e.BeanGauge(c.threads, bean, "ThreadCount")
+			e.BeanGauge(c.threadsDaemon, bean, "DaemonThreadCount")
+			e.BeanGauge(c.threadsPeak, bean, "PeakThreadCount")
+		case "OperatingSystem":
+			c.collectOperatingSystem(e, bean)
+		case "Runtime":
+			c.collectRuntime(e, bean)
+		case "ClassLoading":
+			e.BeanGauge(c.classesLoaded, bean, "LoadedClassCount")
+			e.BeanCounter(c.classesLoadedTotal, bean, "TotalLoadedClassCount")
+			e.BeanCounter(c.classesUnloadedTotal, bean, "UnloadedClassCount")
+		}
+	case keys["name"] == "JvmMetrics":
+		c.collectJvmMetrics(e, bean)
+	}
+}
+
+// collectUsage sends a MemoryUsage attribute, e.g. HeapMemoryUsage:
+// {"committed":1073741824,"init":1073741824,"max":1073741824,"used":425684896}.
+// An undefined max, -1, is skipped.
+func (c *JVMCollector) collectUsage(e *Emitter, d *MetricDesc, bean *Bean, attr, label string) {
+	usage, ok := bean.Object(attr)
+	if !ok {
+		return
+	}
+	for _, mode := range []string{"committed", "init", "max", "used"} {
+		if v, ok := usage.Float(mode); ok && v >= 0 {
+			e.Gauge(d, v, label, mode)
+		}
+	}
+}
+
+// collectOperatingSystem sends the process metrics of the OperatingSystem
+// bean. The file descriptors are known on Unix only, and the CPU load and
+// load average are negative while unavailable.
+func (c *JVMCollector) collectOperatingSystem(e *Emitter, bean *Bean) {
+	if bean.Has("OpenFileDescriptorCount") {
+		e.BeanGauge(c.openFDs, bean, "OpenFileDescriptorCount")
+		e.BeanGauge(c.maxFDs, bean, "MaxFileDescriptorCount")
+	}
+	if v, ok := bean.Float("ProcessCpuTime"); ok && v >= 0 {
+		e.Counter(c.cpuTime, v/1e9)
+	}
+	if v, ok := bean.Float("ProcessCpuLoad"); ok && v >= 0 {
+		e.Gauge(c.cpuLoad, v)
+	}
+	e.BeanGauge(c.virtualMemory, bean, "CommittedVirtualMemorySize")
+	if v, ok := bean.Float("SystemLoadAverage"); ok && v >= 0 {
+		e.Gauge(c.loadAverage, v)
+	}
+	e.BeanGauge(c.processors, bean, "AvailableProcessors")
+}
+
+// collectRuntime sends the start time, uptime and version of the JVM from the
+// Runtime bean.
+func (c *JVMCollector) collectRuntime(e *Emitter, bean *Bean) {
+	if v, ok := bean.Float("StartTime"); ok {
+		e.Gauge(c.startTime, v/1000)
+	}
+	if v, ok := bean.Float("Uptime"); ok {
+		e.Gauge(c.uptime, v/1000)
+	}
+	vendor, vendorOK := bean.String("VmVendor")
+	version, versionOK := bean.String("VmVersion")
+	if vendorOK && versionOK {
+		e.Gauge(c.info, 1, vendor, version)
+	}
+}
+
+// collectJvmMetrics sends the thread states, JVM pauses and Log4j events of
+// the JvmMetrics bean of Hadoop. The JvmPauseMonitor and Log4j counters are
+// missing where they are disabled.
+func (c *JVMCollector) collectJvmMetrics(e *Emitter, bean *Bean) {
+	for _, attr := range bean.Keys() {
+		if state, ok := jvmThreadStates[attr]; ok {
+			e.BeanGauge(c.threadsState, bean, attr, state)
+		}
+		if level, ok := jvmLogLevels[attr]; ok {
+			e.BeanCounter(c.logEvents, bean, attr, level)
+		}
+		switch attr {
+		case "GcNumWarnThresholdExceeded":
+			e.BeanCounter(c.gcWarnThreshold, bean, attr)
+		case "GcNumInfoThresholdExceeded":
+			e.BeanCounter(c.gcInfoThreshold, bean, attr)
+		case "GcTotalExtraSleepTime":
+			if v, ok := bean.Float(attr); ok {
+				e.Counter(c.gcExtraSleep, v/1000)
+			}
+		}
+	}
+}
@@ -1,78 +1,296 @@
 package lib
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/jcmturner/gokrb5.v7/client"
 	"gopkg.in/jcmturner/gokrb5.v7/config"
+	"gopkg.in/jcmturner/gokrb5.v7/credentials"
+	"gopkg.in/jcmturner/gokrb5.v7/iana/nametype"
 	"gopkg.in/jcmturner/gokrb5.v7/keytab"
 	"gopkg.in/jcmturner/gokrb5.v7/spnego"
+	"gopkg.in/jcmturner/gokrb5.v7/types"
 )
 
-func CreateKerberosClientWithPassword(pricipal string, password string) (*client.Client, error) {
+// DefaultKrb5ConfPath is the krb5.conf used when none is configured.
+const DefaultKrb5ConfPath = "/etc/krb5.conf"
+
+// Krb5PasswordEnv is the environment variable the Kerberos password is read
+// from when no password file is configured.
+const Krb5PasswordEnv = "KRB5_PASSWORD"
+
+// Krb5Auth holds the credentials an exporter logs in with. Requests are sent
+// without SPNEGO when neither a principal nor a credential cache is configured.
+// A principal logs in with its keytab or password, ignoring the credential
+// cache, which may come from the environment.
+type Krb5Auth struct {
+	ConfPath     string `yaml:"config_path"`
+	KeytabPath   string `yaml:"keytab_path"`
+	Principal    string `yaml:"principal"`
+	PasswordFile string `yaml:"password_file"`
+	CCachePath   string `yaml:"ccache_path"`
+
+	// SPN overrides the HTTP/<host of the URL> service principal.
+	SPN string `yaml:"spn"`
+	// CanonicalizeSPN resolves the host of the URL through DNS CNAME records
+	// before building the SPN, for daemons scraped through an alias.
+	CanonicalizeSPN bool `yaml:"spn_canonicalize"`
+}
+
+// Krb5AuthFlags registers the Kerberos flags shared by all exporters on the
+// default flag set. The returned Krb5Auth is filled in by flag.Parse.
+func Krb5AuthFlags() *Krb5Auth {
+	a := &Krb5Auth{}
+	flag.StringVar(&a.ConfPath, "krb5.config.path", DefaultKrb5ConfPath, "Kerberos config file path")
+	flag.StringVar(&a.KeytabPath, "krb5.keytab.path", "", "Kerberos keytab file path")
+	flag.StringVar(&a.Principal, "krb5.principal", "", "Principal (admin@EXAMPLE.COM)")
+	flag.StringVar(&a.PasswordFile, "krb5.password.file", "", "File holding the password of the principal, used when no keytab is given (default $"+Krb5PasswordEnv+")")
+	flag.StringVar(&a.CCachePath, "krb5.ccache.path", os.Getenv("KRB5CCNAME"), "Kerberos credential cache to log in from when no principal is given, a path or FILE:<path>, taken from KRB5CCNAME by default")
+	flag.StringVar(&a.SPN, "krb5.spn", "", "Service principal of the scraped daemon (default HTTP/<host of the URL>)")
+	flag.BoolVar(&a.CanonicalizeSPN, "krb5.spn.canonicalize", false, "Resolve the host of the URL through DNS CNAME records before building the SPN")
+	return a
+}
+
+// Enabled reports whether requests are authenticated with SPNEGO.
+func (a Krb5Auth) Enabled() bool {
+	return a.Principal != "" || a.CCachePath != ""
+}
+
+// Get fetches the url, with SPNEGO authentication when enabled, and returns
+// the body of a 2xx response.
+func (a Krb5Auth) Get(url string) ([]byte, error) {
+	r, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	if err := a.Authorize(r); err != nil {
+		return nil, err
+	}
+
+	return do(http.DefaultClient, r)
+}
+
+// Authorize sets the SPNEGO header on the request when enabled. A token must
+// not be sent twice, so every attempt of a request has to be authorized anew.
+func (a Krb5Auth) Authorize(r *http.Request) error {
+	if !a.Enabled() {
+		return nil
+	}
+
+	krb5cli, err := a.client()
+	if err != nil {
+		return fmt.Errorf("could not create krb5 client: %w", err)
+	}
+
+	spn, err := a.spn(r.URL.String())
+	if err != nil {
+		return err
+	}
+
+	return krb5cli.Authorize(r, spn)
+}
+
+// client returns the cached KerberosClient of the configured credentials.
+// Clients are cached by credentials, so exporters of different principals,
+// keytabs or krb5.conf files do not share a login.
+func (a Krb5Auth) client() (*KerberosClient, error) {
+	confPath := a.ConfPath
+	if confPath == "" {
+		confPath = DefaultKrb5ConfPath
+	}
+
+	if a.CCachePath != "" && a.Principal == "" {
+		ccachePath, err := ccacheFile(a.CCachePath)
+		if err != nil {
+			return nil, err
+		}
+		return getKerberosClient(krb5Key("ccache", confPath, ccachePath, ""), true, func(times *tgtTimes) (*client.Client, error) {
+			cli, expiry, err := CreateKerberosClientFromCCache(confPath, ccachePath)
+			if err != nil {
+				return nil, err
+			}
+			times.set(time.Now(), expiry)
+			return cli, nil
+		})
+	}
+
+	if a.KeytabPath != "" {
+		return getKerberosClient(krb5Key("keytab", confPath, a.KeytabPath, a.Principal), false, func(times *tgtTimes) (*client.Client, error) {
+			return CreateKerberosClientWithKeytab(confPath, a.KeytabPath, a.Principal, times.watch())
+		})
+	}
+
+	return getKerberosClient(krb5Key("password", confPath, a.PasswordFile, a.Principal), false, func(times *tgtTimes) (*client.Client, error) {
+		password, err := a.password()
+		if err != nil {
+			return nil, err
+		}
+		return CreateKerberosClientWithPassword(confPath, a.Principal, password, times.watch())
+	})
+}
+
+// ccacheFile returns the path of a credential cache named as in KRB5CCNAME,
+// e.g. FILE:/tmp/krb5cc_1000 or /tmp/krb5cc_1000. gokrb5 reads only FILE
+// caches; KEYRING:, DIR:, KCM: and other types are refused.
+func ccacheFile(name string) (string, error) {
+	i := strings.Index(name, ":")
+	if i <= 0 || strings.Contains(name[:i], "/") {
+		return name, nil
+	}
+	if typ := name[:i]; typ != "FILE" {
+		return "", fmt.Errorf("%w: credential cache %s is of type %s, only FILE caches are supported", ErrKrb5Config, name, typ)
+	}
+	return name[i+1:], nil
+}
+
+// krb5Key returns the key of the cached KerberosClient of the credentials.
+func krb5Key(kind, confPath, path, principal string) string {
+	return strings.Join([]string{kind, confPath, path, principal}, "\x00")
+}
+
+// password reads the password from PasswordFile, falling back to the
+// Krb5PasswordEnv environment variable. Passwords are never taken from flags
+// so they do not show up in the process list.
+func (a Krb5Auth) password() (string, error) {
+	if a.PasswordFile != "" {
+		b, err := ioutil.ReadFile(a.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to read password file: %w", ErrKrb5Config, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	if password, ok := os.LookupEnv(Krb5PasswordEnv); ok {
+		return password, nil
+	}
+
+	return "", fmt.Errorf("%w: neither a keytab nor a password is configured for %s", ErrKrb5Config, a.Principal)
+}
+
+// spn returns the service principal to request a ticket for.
+func (a Krb5Auth) spn(u string) (string, error) {
+	if a.SPN != "" {
+		return a.SPN, nil
+	}
+
+	fqdn, err := extractDomainFromURL(u)
+	if err != nil {
+		return "", fmt.Errorf("could not extract fqdn from url: %w", err)
+	}
+
+	if a.CanonicalizeSPN {
+		cname, err := net.LookupCNAME(fqdn)
+		if err != nil {
+			return "", fmt.Errorf("could not canonicalize %s: %w", fqdn, err)
+		}
+		fqdn = strings.TrimSuffix(cname, ".")
+	}
+
+	return fmt.Sprintf("HTTP/%s", fqdn), nil
+}
+
+func CreateKerberosClientWithPassword(krb5ConfPath string, pricipal string, password string, settings ...func(*client.Settings)) (*client.Client, error) {
 
 	// Load the client krb5 config
-	cfg, err := config.Load("/etc/krb5.conf")
+	cfg, err := config.Load(krb5ConfPath)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract username and realm from pricipal")
+		return nil, fmt.Errorf("%w: failed to load Kerberos config: %w", ErrKrb5Config, err)
 
 	}
 
 	username, realm := ExtractUsernameAndRealm(pricipal)
 
 	if username == "" {
-		return nil, fmt.Errorf("failed to extract username and realm from pricipal")
+		return nil, fmt.Errorf("%w: failed to extract username and realm from pricipal", ErrKrb5Config)
 
 	}
 
-	cli := client.NewClientWithPassword(username, realm, password, cfg)
+	cli := client.NewClientWithPassword(username, realm, password, cfg, settings...)
 
 	// Log in the client
 	err = cli.Login()
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract username and realm from pricipal")
+		return nil, krb5Error(err, "failed to log in as %s", pricipal)
 
 	}
 
 	return cli, nil
 }
 
-func CreateKerberosClientWithKeytab(ktPath string, pricipal string) (*client.Client, error) {
+func CreateKerberosClientWithKeytab(krb5ConfPath string, ktPath string, pricipal string, settings ...func(*client.Settings)) (*client.Client, error) {
 	// https://github.com/jcmturner/gokrb5/blob/855dbc707a37a21467aef6c0245fcf3328dc39ed/USAGE.md?plain=1#L20
 	kt, err := keytab.Load(ktPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load keytab file: %v", err)
+		return nil, fmt.Errorf("%w: failed to load keytab file: %w", ErrBadKeytab, err)
 	}
 
-	krb5Conf, err := config.Load("/etc/krb5.conf")
+	krb5Conf, err := config.Load(krb5ConfPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load Kerberos config: %v", err)
+		return nil, fmt.Errorf("%w: failed to load Kerberos config: %w", ErrKrb5Config, err)
 	}
 
 	username, realm := ExtractUsernameAndRealm(pricipal)
 
 	if username == "" {
-		return nil, fmt.Errorf("failed to extract username and realm from pricipal")
+		return nil, fmt.Errorf("%w: failed to extract username and realm from pricipal", ErrKrb5Config)
 	}
 
-	cli := client.NewClientWithKeytab(username, realm, kt, krb5Conf)
+	cli := client.NewClientWithKeytab(username, realm, kt, krb5Conf, settings...)
 
 	// Log in the client
 	err = cli.Login()
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract username and realm from pricipal")
+		return nil, krb5Error(err, "failed to log in as %s", pricipal)
 
 	}
 
 	return cli, nil
 }
 
+// CreateKerberosClientFromCCache creates a client from the TGT of a credential
+// cache filled by kinit, and returns it with the expiry of that TGT. Such a
+// client cannot renew the TGT itself, the cache has to be kept fresh externally.
+func CreateKerberosClientFromCCache(krb5ConfPath string, ccachePath string) (*client.Client, time.Time, error) {
+	ccache, err := credentials.LoadCCache(ccachePath)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: failed to load credential cache: %w", ErrKrb5Config, err)
+	}
+
+	krb5Conf, err := config.Load(krb5ConfPath)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: failed to load Kerberos config: %w", ErrKrb5Config, err)
+	}
+
+	tgt, ok := ccache.GetEntry(types.PrincipalName{
+		NameType:   nametype.KRB_NT_SRV_INST,
+		NameString: []string{"krbtgt", ccache.GetClientRealm()},
+	})
+	if !ok || time.Now().After(tgt.EndTime) {
+		return nil, time.Time{}, fmt.Errorf("%w: no valid TGT in credential cache %s", ErrKrb5Login, ccachePath)
+	}
+
+	cli, err := client.NewClientFromCCache(ccache, krb5Conf)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("%w: failed to load credential cache: %w", ErrKrb5Config, err)
+	}
+
+	return cli, tgt.EndTime, nil
+}
+
 func ExtractUsernameAndRealm(pricipal string) (string, string) {
 	parts := strings.Split(pricipal, "@")
 	if len(parts) != 2 {
@@ -92,59 +310,310 @@ func extractDomainFromURL(u string) (string, error) {
 	return host, nil
 }
 
-func MakeKrb5Request(client *client.Client, url string) []byte {
+func MakeKrb5Request(client *client.Client, url string) ([]byte, error) {
+	spn, err := Krb5Auth{}.spn(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return doKrb5Request(client, http.DefaultClient, url, spn)
+}
+
+func MakeKrb5RequestWithKeytab(ktPath string, pricipal string, url string) ([]byte, error) {
+	return Krb5Auth{KeytabPath: ktPath, Principal: pricipal}.Get(url)
+}
+
+func MakeKrb5RequestWithPassword(pricipal string, password string, url string) ([]byte, error) {
+
+	krb5cli, err := getKerberosClient(krb5Key("password", DefaultKrb5ConfPath, "", pricipal), false, func(times *tgtTimes) (*client.Client, error) {
+		return CreateKerberosClientWithPassword(DefaultKrb5ConfPath, pricipal, password, times.watch())
+	})
 
-	r, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Fatalf("could not create request: %v", err)
+		return nil, fmt.Errorf("could not create krb5 client: %w", err)
 	}
 
-	fqdn, err := extractDomainFromURL(url)
+	spn, err := Krb5Auth{}.spn(url)
 	if err != nil {
-		log.Fatalf("could not extract fqdn from url: %v", err)
+		return nil, err
 	}
 
-	spn := fmt.Sprintf("HTTP/%s", fqdn)
+	return krb5cli.Get(url, spn)
 
-	spnegoCl := spnego.NewClient(client, nil, spn)
+}
+
+// doKrb5Request sends a GET request carrying a SPNEGO token for the SPN and
+// returns the body of a 2xx response.
+func doKrb5Request(cli *client.Client, httpCli *http.Client, url string, spn string) ([]byte, error) {
 
-	// Make the request
-	resp, err := spnegoCl.Do(r)
+	r, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Fatalf("error making request: %v", err)
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	if err := setSPNEGOHeader(cli, r, spn); err != nil {
+		return nil, err
 	}
+
+	return do(httpCli, r)
+
+}
+
+func setSPNEGOHeader(cli *client.Client, r *http.Request, spn string) error {
+	// The ticket is kept in the client's cache, so SetSPNEGOHeader below
+	// only talks to the KDC when there is no valid ticket for the SPN yet.
+	if _, _, err := cli.GetServiceTicket(spn); err != nil {
+		return krb5Error(err, "could not get service ticket for %s", spn)
+	}
+	if err := spnego.SetSPNEGOHeader(cli, r, spn); err != nil {
+		return fmt.Errorf("%w: %w", ErrSPNEGORejected, err)
+	}
+
+	return nil
+}
+
+func do(httpCli *http.Client, r *http.Request) ([]byte, error) {
+	url := r.URL.String()
+
+	resp, err := httpCli.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized && r.Header.Get(spnego.HTTPHeaderAuthRequest) != "" {
+		return nil, fmt.Errorf("%w by %s", ErrSPNEGORejected, url)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("error reading response body: %v", err)
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
-	// fmt.Println(string(body))
 
-	defer resp.Body.Close()
+	return body, nil
+}
 
-	return body
+// krb5Login returns a logged in client, recording the times of its TGT.
+type krb5Login func(times *tgtTimes) (*client.Client, error)
 
+// tgtTimes holds when the TGT of a client was obtained and when it expires.
+type tgtTimes struct {
+	mu       sync.Mutex
+	realm    string
+	obtained time.Time
+	expiry   time.Time
 }
 
-func MakeKrb5RequestWithKeytab(ktPath string, pricipal string, url string) []byte {
+// tgtSession matches the log lines of gokrb5 about the TGT sessions of a
+// client, e.g. "TGT session renewed for EXAMPLE.COM (EndTime: 2024-05-01
+// 10:00:00 +0000 UTC)". gokrb5 v7 keeps the sessions and their times
+// unexported, so the logs are the only way to follow a keytab or password
+// login; a credential cache login reads the EndTime of the cached TGT.
+var tgtSession = regexp.MustCompile(`TGT session (?:added|renewed) for (\S+) \(EndTime: ([^)]+?)(?: m=[^)]*)?\)`)
+
+// watch returns the client setting recording the EndTime of the TGT of the
+// client realm whenever gokrb5 logs in or renews it in the background.
+func (t *tgtTimes) watch() func(*client.Settings) {
+	return client.Logger(log.New(t, "", 0))
+}
 
-	krb5cli, err := CreateKerberosClientWithKeytab(ktPath, pricipal)
+// Write implements the io.Writer interface for the logger of the client.
+func (t *tgtTimes) Write(p []byte) (int, error) {
+	if m := tgtSession.FindSubmatch(p); m != nil {
+		if endTime, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", string(m[2])); err == nil {
+			t.mu.Lock()
+			if t.realm == "" || t.realm == string(m[1]) {
+				t.obtained = time.Now()
+				t.expiry = endTime
+			}
+			t.mu.Unlock()
+		}
+	}
+	return len(p), nil
+}
 
-	if err != nil {
-		log.Fatalf("could not create krb5 client: %v", err)
+func (t *tgtTimes) set(obtained, expiry time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.obtained = obtained
+	t.expiry = expiry
+}
+
+func (t *tgtTimes) get() (obtained, expiry time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.obtained, t.expiry
+}
+
+// KerberosClient keeps a logged in Kerberos client alive between scrapes.
+// gokrb5 renews the TGT of a keytab or password login in the background, and
+// service tickets are cached per SPN by the underlying client. A client from
+// a credential cache cannot renew its TGT, so the cache is loaded again when
+// five sixths of the lifetime of its TGT have passed.
+type KerberosClient struct {
+	login  krb5Login
+	reload bool
+	http   *http.Client
+
+	// loginMu serializes the logins, which talk to the KDC, so that mu is
+	// only held briefly.
+	loginMu sync.Mutex
+
+	mu        sync.Mutex
+	cli       *client.Client
+	principal string
+	times     *tgtTimes
+	renewAt   time.Time
+}
+
+var krb5Clients = struct {
+	sync.Mutex
+	m map[string]*KerberosClient
+}{m: make(map[string]*KerberosClient)}
+
+// getKerberosClient returns the cached client of the key, logging it in on
+// first use. The KDC is not contacted with krb5Clients locked, so a slow KDC
+// only delays the targets sharing its credentials.
+func getKerberosClient(key string, reload bool, login krb5Login) (*KerberosClient, error) {
+	krb5Clients.Lock()
+	k, ok := krb5Clients.m[key]
+	if !ok {
+		k = &KerberosClient{
+			login:  login,
+			reload: reload,
+			http:   &http.Client{},
+		}
+		krb5Clients.m[key] = k
 	}
+	krb5Clients.Unlock()
 
-	return MakeKrb5Request(krb5cli, url)
+	if _, err := k.loggedIn(); err != nil {
+		return nil, err
+	}
 
+	return k, nil
 }
 
-func MakeKrb5RequestWithPassword(pricipal string, password string, url string) []byte {
+// loggedIn returns the client, logging in when there is none yet or, for a
+// credential cache, when five sixths of the lifetime of its TGT have passed.
+func (k *KerberosClient) loggedIn() (*client.Client, error) {
+	k.loginMu.Lock()
+	defer k.loginMu.Unlock()
+
+	k.mu.Lock()
+	cli, renewAt := k.cli, k.renewAt
+	k.mu.Unlock()
+	now := time.Now()
+	if cli != nil && (!k.reload || now.Before(renewAt)) {
+		return cli, nil
+	}
 
-	krb5cli, err := CreateKerberosClientWithPassword(pricipal, password)
+	times := &tgtTimes{}
+	cli, err := k.login(times)
+	if err != nil {
+		return nil, err
+	}
+	times.mu.Lock()
+	times.realm = cli.Credentials.Domain()
+	expiry := times.expiry
+	times.mu.Unlock()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.cli = cli
+	k.principal = fmt.Sprintf("%s@%s", cli.Credentials.CName().PrincipalNameString(), cli.Credentials.Domain())
+	k.times = times
+	k.renewAt = time.Time{}
+	if !expiry.IsZero() {
+		k.renewAt = now.Add(expiry.Sub(now) * 5 / 6)
+	}
 
+	return cli, nil
+}
+
+// Get fetches the url with SPNEGO authentication for the SPN and returns the body.
+func (k *KerberosClient) Get(url string, spn string) ([]byte, error) {
+	cli, err := k.loggedIn()
+	if err != nil {
+		return nil, err
+	}
+
+	return doKrb5Request(cli, k.http, url, spn)
+}
+
+// Authorize sets the SPNEGO header for the SPN on the request.
+func (k *KerberosClient) Authorize(r *http.Request, spn string) error {
+	cli, err := k.loggedIn()
 	if err != nil {
-		log.Fatalf("could not create krb5 client: %v", err)
+		return err
 	}
 
-	return MakeKrb5Request(krb5cli, url)
+	return setSPNEGOHeader(cli, r, spn)
+}
+
+var (
+	krb5TGTExpiryDesc = prometheus.NewDesc(
+		"hadoop_exporter_kerberos_tgt_expiry_timestamp_seconds",
+		"Expiry of the Kerberos TGT, as granted by the KDC or taken from the credential cache",
+		[]string{"principal"}, nil,
+	)
+	krb5LastLoginDesc = prometheus.NewDesc(
+		"hadoop_exporter_kerberos_last_login_timestamp_seconds",
+		"Time the Kerberos TGT was last obtained or renewed",
+		[]string{"principal"}, nil,
+	)
+)
+
+// KerberosCollector exports the login state of every cached KerberosClient.
+type KerberosCollector struct{}
+
+// Describe implements the prometheus.Collector interface.
+func (KerberosCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- krb5TGTExpiryDesc
+	ch <- krb5LastLoginDesc
+}
 
+// Collect implements the prometheus.Collector interface.
+func (KerberosCollector) Collect(ch chan<- prometheus.Metric) {
+	krb5Clients.Lock()
+	clients := make([]*KerberosClient, 0, len(krb5Clients.m))
+	for _, k := range krb5Clients.m {
+		clients = append(clients, k)
+	}
+	krb5Clients.Unlock()
+
+	// Several credentials, e.g. keytabs, may log in the same principal;
+	// the TGT expiring first is reported. The expiry stays unreported as
+	// long as no EndTime is known for any of them.
+	type login struct{ lastLogin, expiry time.Time }
+	logins := map[string]login{}
+	for _, k := range clients {
+		k.mu.Lock()
+		principal, times := k.principal, k.times
+		k.mu.Unlock()
+		if times == nil {
+			// Never logged in.
+			continue
+		}
+
+		lastLogin, expiry := times.get()
+		l, ok := logins[principal]
+		if !ok || lastLogin.After(l.lastLogin) {
+			l.lastLogin = lastLogin
+		}
+		if !expiry.IsZero() && (l.expiry.IsZero() || expiry.Before(l.expiry)) {
+			l.expiry = expiry
+		}
+		logins[principal] = l
+	}
+
+	for principal, l := range logins {
+		if !l.expiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(krb5TGTExpiryDesc, prometheus.GaugeValue, float64(l.expiry.Unix()), principal)
+		}
+		ch <- prometheus.MustNewConstMetric(krb5LastLoginDesc, prometheus.GaugeValue, float64(l.lastLogin.Unix()), principal)
+	}
 }
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestTGTTimesWrite(t *testing.T) {
+	endTime := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name  string
+		realm string
+		line  string
+		want  time.Time
+	}{
+		{
+			name: "added",
+			line: fmt.Sprintf("TGT session added for %s (EndTime: %v)", "EXAMPLE.COM", endTime),
+			want: endTime,
+		},
+		{
+			name:  "renewed",
+			realm: "EXAMPLE.COM",
+			line:  fmt.Sprintf("TGT session renewed for %s (EndTime: %v)", "EXAMPLE.COM", endTime),
+			want:  endTime,
+		},
+		{
+			name:  "monotonic clock",
+			realm: "EXAMPLE.COM",
+			line:  "TGT session renewed for EXAMPLE.COM (EndTime: 2024-05-01 10:00:00.123456789 +0200 CEST m=+36000.000000001)",
+			want:  time.Date(2024, 5, 1, 8, 0, 0, 123456789, time.UTC),
+		},
+		{
+			name:  "other realm",
+			realm: "EXAMPLE.COM",
+			line:  fmt.Sprintf("TGT session renewed for %s (EndTime: %v)", "OTHER.COM", endTime),
+		},
+		{
+			name: "other session",
+			line: fmt.Sprintf("TGS session added for %s (EndTime: %v)", "EXAMPLE.COM", endTime),
+		},
+		{
+			name: "unparsable EndTime",
+			line: "TGT session added for EXAMPLE.COM (EndTime: tomorrow)",
+		},
+		{
+			name: "garbage",
+			line: "AS Exchange Error",
+		},
+	} {
+		times := &tgtTimes{realm: tt.realm}
+		if n, err := times.Write([]byte(tt.line + "\n")); n != len(tt.line)+1 || err != nil {
+			t.Errorf("%s: Write() = %d, %v, want %d, nil", tt.name, n, err, len(tt.line)+1)
+		}
+		obtained, expiry := times.get()
+		if tt.want.IsZero() {
+			if !expiry.IsZero() || !obtained.IsZero() {
+				t.Errorf("%s: get() = %v, %v, want zero times", tt.name, obtained, expiry)
+			}
+			continue
+		}
+		if !expiry.Equal(tt.want) {
+			t.Errorf("%s: expiry = %v, want %v", tt.name, expiry, tt.want)
+		}
+		if obtained.IsZero() {
+			t.Errorf("%s: obtained is zero", tt.name)
+		}
+	}
+}
+
+func TestKerberosCollectorZeroExpiry(t *testing.T) {
+	now := time.Now()
+	krb5Clients.Lock()
+	krb5Clients.m["test-unknown"] = &KerberosClient{principal: "unknown@EXAMPLE.COM", times: &tgtTimes{obtained: now}}
+	krb5Clients.m["test-known"] = &KerberosClient{principal: "known@EXAMPLE.COM", times: &tgtTimes{obtained: now, expiry: now.Add(time.Hour)}}
+	krb5Clients.Unlock()
+	defer func() {
+		krb5Clients.Lock()
+		delete(krb5Clients.m, "test-unknown")
+		delete(krb5Clients.m, "test-known")
+		krb5Clients.Unlock()
+	}()
+
+	ch := make(chan prometheus.Metric, 10)
+	KerberosCollector{}.Collect(ch)
+	close(ch)
+	got := map[string]int{}
+	for m := range ch {
+		got[m.Desc().String()]++
+	}
+	if got[krb5TGTExpiryDesc.String()] != 1 {
+		t.Errorf("Collect() sent %d TGT expiries, want 1 for the known EndTime", got[krb5TGTExpiryDesc.String()])
+	}
+	if got[krb5LastLoginDesc.String()] != 2 {
+		t.Errorf("Collect() sent %d last logins, want 2", got[krb5LastLoginDesc.String()])
+	}
+}
+
+func TestCCacheFile(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "/tmp/krb5cc_1000", want: "/tmp/krb5cc_1000"},
+		{name: "FILE:/tmp/krb5cc_1000", want: "/tmp/krb5cc_1000"},
+		{name: "krb5cc_prometheus", want: "krb5cc_prometheus"},
+		{name: "/var/run/krb5:prometheus", want: "/var/run/krb5:prometheus"},
+		{name: "KEYRING:persistent:1000", wantErr: true},
+		{name: "DIR:/run/user/1000/krb5cc", wantErr: true},
+		{name: "KCM:1000", wantErr: true},
+		{name: "MEMORY:prometheus", wantErr: true},
+	} {
+		got, err := ccacheFile(tt.name)
+		if tt.wantErr {
+			if !errors.Is(err, ErrKrb5Config) {
+				t.Errorf("ccacheFile(%q) error = %v, want %v", tt.name, err, ErrKrb5Config)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ccacheFile(%q) = %q, %v, want %q, nil", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// Module is a way of scraping a daemon on demand through /probe: its role
+// and the authentication to use.
+type Module struct {
+	Role   *Role
+	Scheme string
+	Auth   Krb5Auth
+	rules  *Rules
+}
+
+// ProbeConfig is the YAML file of probe modules:
+//
+//	modules:
+//	  datanode_https:
+//	    role: datanode
+//	    scheme: https
+//	    krb5:
+//	      principal: prometheus@EXAMPLE.COM
+//	      keytab_path: /etc/security/keytabs/prometheus.keytab
+type ProbeConfig struct {
+	Modules map[string]ModuleConfig `yaml:"modules"`
+}
+
+// ModuleConfig configures a Module. Without krb5 the module authenticates
+// like the exporter itself.
+type ModuleConfig struct {
+	Role   string    `yaml:"role"`
+	Scheme string    `yaml:"scheme"`
+	Krb5   *Krb5Auth `yaml:"krb5"`
+}
+
+// LoadModules returns a module named after every role, authenticating with
+// auth, and the modules of the file at path, if any. The metrics of all
+// modules follow the rules of the file at rulesPath and those of their role.
+func LoadModules(path string, roles []*Role, auth Krb5Auth, rulesPath string) (map[string]*Module, error) {
+	rules := map[*Role]*Rules{}
+	for _, role := range roles {
+		r, err := LoadRules(role.Namespace, role.Rules, rulesPath)
+		if err != nil {
+			return nil, err
+		}
+		rules[role] = r
+	}
+
+	modules := map[string]*Module{}
+	for _, role := range roles {
+		modules[role.Name] = &Module{Role: role, Scheme: "http", Auth: auth, rules: rules[role]}
+	}
+	if path == "" {
+		return modules, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read probe modules: %w", err)
+	}
+	var c ProbeConfig
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid probe modules %s: %w", path, err)
+	}
+
+	for name, mc := range c.Modules {
+		m := &Module{Scheme: mc.Scheme, Auth: auth}
+		for _, role := range roles {
+			if role.Name == mc.Role {
+				m.Role = role
+			}
+		}
+		if m.Role == nil {
+			return nil, fmt.Errorf("probe module %s: unknown role %q", name, mc.Role)
+		}
+		m.rules = rules[m.Role]
+
+		switch m.Scheme {
+		case "":
+			m.Scheme = "http"
+		case "http", "https":
+		default:
+			return nil, fmt.Errorf("probe module %s: unknown scheme %q", name, mc.Scheme)
+		}
+		if mc.Krb5 != nil {
+			m.Auth = *mc.Krb5
+		}
+		modules[name] = m
+	}
+	return modules, nil
+}
+
+// ProbeHandler scrapes the daemon at the host:port given by the target
+// parameter with the module given by the module parameter, e.g.
+// /probe?target=dn042:9864&module=datanode. Only the metrics of that daemon
+// are served, the error counters of the scrape included, so nothing is kept
+// by target once the request is served.
+func ProbeHandler(modules map[string]*Module, opts JmxOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		name := params.Get("module")
+		m, ok := modules[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown module %q", name), http.StatusBadRequest)
+			return
+		}
+
+		target := params.Get("target")
+		u, err := url.Parse(m.Scheme + "://" + target)
+		if target == "" || err != nil || u.Host != target {
+			http.Error(w, fmt.Sprintf("target %q is not a host:port", target), http.StatusBadRequest)
+			return
+		}
+
+		metrics := newScrapeMetrics(probeAttributeLog)
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(metrics)
+
+		client := NewJmxClient(m.Scheme+"://"+target+m.Role.Path, m.Auth, opts)
+		r = r.WithContext(withScrapeMetrics(r.Context(), metrics))
+		serveCollector(w, r, m.Role.NewExporter(client, m.rules), reg)
+	})
+}
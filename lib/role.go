@@ -0,0 +1,57 @@
+package lib
+
+// Role is a kind of Hadoop daemon the exporter can scrape.
+type Role struct {
+	// Name is the subcommand of the role, e.g. "namenode".
+	Name string
+	// Title names the daemon on the index page, e.g. "NameNode".
+	Title string
+	// Service is the service key of the daemon's beans, as in
+	// Hadoop:service=NameNode,name=FSNamesystem.
+	Service string
+	// Namespace prefixes the metrics of the role's rules.
+	Namespace string
+	// ListenAddress and URL are the defaults of -web.listen-address and
+	// -daemon.url.
+	ListenAddress string
+	URL           string
+	// Path is appended to the URL of the daemon, e.g. "/jmx".
+	Path string
+	// LegacyURLFlag is the name of the URL flag of the former per-role
+	// binary, still accepted.
+	LegacyURLFlag string
+	// RegisterFlags, if set, registers the flags of the role on the default
+	// flag set.
+	RegisterFlags func()
+	// Rules are the built-in rules of the role in YAML.
+	Rules []byte
+	// NewExporter returns the collector of a daemon of the role.
+	NewExporter func(client *JmxClient, rules *Rules) ContextCollector
+	// NewGroupExporter, if set, returns the collector of several daemons of
+	// the role scraped together, such as the NameNodes of a nameservice.
+	NewGroupExporter func(daemons []Daemon, rules *Rules) ContextCollector
+}
+
+// Daemon is one of several daemons scraped together.
+type Daemon struct {
+	// ID names the daemon in the metrics, e.g. the nn_id of a NameNode.
+	ID     string
+	Client *JmxClient
+}
+
+// DetectRole returns the role whose service appears in the names of the
+// beans of a /jmx response.
+func DetectRole(beans []*Bean, roles []*Role) (*Role, bool) {
+	for _, bean := range beans {
+		service := objectNameKeys(bean.Name())["service"]
+		if service == "" {
+			continue
+		}
+		for _, role := range roles {
+			if role.Service == service {
+				return role, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rpcPhases names the phase label of the RPC timings by attribute prefix.
+var rpcPhases = map[string]string{
+	"RpcQueueTime":              "queue",
+	"RpcProcessingTime":         "processing",
+	"RpcLockWaitTime":           "lock_wait",
+	"RpcResponseTime":           "response",
+	"DeferredRpcProcessingTime": "deferred_processing",
+}
+
+var (
+	// rpcTiming matches the timing attributes of RpcActivityForPort<port>,
+	// e.g. RpcQueueTimeNumOps or RpcProcessingTimeAvgTime.
+	rpcTiming = regexp.MustCompile(`^(RpcQueueTime|RpcProcessingTime|RpcLockWaitTime|RpcResponseTime|DeferredRpcProcessingTime)(NumOps|AvgTime)$`)
+	// rpcQuantile matches the percentiles of the timings over an interval,
+	// enabled with rpc.metrics.percentiles.intervals, e.g.
+	// RpcQueueTime60s99thPercentileLatency.
+	rpcQuantile = regexp.MustCompile(`^(RpcQueueTime|RpcProcessingTime|RpcLockWaitTime|RpcResponseTime|DeferredRpcProcessingTime)(\d+)s(\d+)thPercentileLatency$`)
+	// rpcIntervalOps matches the number of calls the percentiles are taken
+	// over, e.g. RpcQueueTime60sNumOps.
+	rpcIntervalOps = regexp.MustCompile(`^(RpcQueueTime|RpcProcessingTime|RpcLockWaitTime|RpcResponseTime|DeferredRpcProcessingTime)(\d+)sNumOps$`)
+)
+
+// RPCCollector collects the RpcActivityForPort<port> beans served by every
+// Hadoop daemon with an RPC server.
+type RPCCollector struct {
+	receivedBytes      *MetricDesc
+	sentBytes          *MetricDesc
+	calls              *MetricDesc
+	avgTime            *MetricDesc
+	time               *MetricDesc
+	intervalOps        *MetricDesc
+	authentications    *MetricDesc
+	authorizations     *MetricDesc
+	clientBackoffs     *MetricDesc
+	slowCalls          *MetricDesc
+	droppedConnections *MetricDesc
+	openConnections    *MetricDesc
+	callQueueLength    *MetricDesc
+}
+
+// NewRPCCollector returns the collector of the RPC servers of a role, whose
+// metrics are named <namespace>_rpc_activity_*.
+func NewRPCCollector(namespace string) *RPCCollector {
+	const subsystem = "rpc_activity"
+	return &RPCCollector{
+		receivedBytes:      NewMetricDesc(namespace, subsystem, "received_bytes_total", "Total number of bytes received by the RPC server", "port"),
+		sentBytes:          NewMetricDesc(namespace, subsystem, "sent_bytes_total", "Total number of bytes sent by the RPC server", "port"),
+		calls:              NewMetricDesc(namespace, subsystem, "calls_total", "Total number of RPC calls that went through the phase", "port", "phase"),
+		avgTime:            NewMetricDesc(namespace, subsystem, "avg_time_seconds", "Average time of the RPC calls in the phase in the last interval", "port", "phase"),
+		time:               NewMetricDesc(namespace, subsystem, "time_seconds", "Quantile of the time of the RPC calls in the phase over the last interval", "port", "phase", "interval", "quantile"),
+		intervalOps:        NewMetricDesc(namespace, subsystem, "time_interval_ops", "Number of RPC calls in the phase over the last interval of the quantiles", "port", "phase", "interval"),
+		authentications:    NewMetricDesc(namespace, subsystem, "authentications_total", "Total number of RPC authentications by result", "port", "result"),
+		authorizations:     NewMetricDesc(namespace, subsystem, "authorizations_total", "Total number of RPC authorizations by result", "port", "result"),
+		clientBackoffs:     NewMetricDesc(namespace, subsystem, "client_backoffs_total", "Total number of RPC calls rejected to make the client back off", "port"),
+		slowCalls:          NewMetricDesc(namespace, subsystem, "slow_calls_total", "Total number of RPC calls slower than the others by several standard deviations", "port"),
+		droppedConnections: NewMetricDesc(namespace, subsystem, "dropped_connections_total", "Total number of RPC connections dropped", "port"),
+		openConnections:    NewMetricDesc(namespace, subsystem, "open_connections", "Current number of open RPC connections", "port"),
+		callQueueLength:    NewMetricDesc(namespace, subsystem, "call_queue_length", "Current length of the RPC call queue", "port"),
+	}
+}
+
+// CollectBean implements the BeanCollector interface.
+func (c *RPCCollector) CollectBean(e *Emitter, bean *Bean) {
+	if !strings.HasPrefix(bean.ModelerType(), "RpcActivityForPort") {
+		return
+	}
+	port, ok := bean.String("tag.port")
+	if !ok {
+		return
+	}
+
+	for _, attr := range bean.Keys() {
+		switch attr {
+		case "ReceivedBytes":
+			e.BeanCounter(c.receivedBytes, bean, attr, port)
+		case "SentBytes":
+			e.BeanCounter(c.sentBytes, bean, attr, port)
+		case "RpcAuthenticationFailures":
+			e.BeanCounter(c.authentications, bean, attr, port, "failure")
+		case "RpcAuthenticationSuccesses":
+			e.BeanCounter(c.authentications, bean, attr, port, "success")
+		case "RpcAuthorizationFailures":
+			e.BeanCounter(c.authorizations, bean, attr, port, "failure")
+		case "RpcAuthorizationSuccesses":
+			e.BeanCounter(c.authorizations, bean, attr, port, "success")
+		case "RpcClientBackoff":
+			e.BeanCounter(c.clientBackoffs, bean, attr, port)
+		case "RpcSlowCalls":
+			e.BeanCounter(c.slowCalls, bean, attr, port)
+		case "NumDroppedConnections":
+			e.BeanCounter(c.droppedConnections, bean, attr, port)
+		case "NumOpenConnections":
+			e.BeanGauge(c.openConnections, bean, attr, port)
+		case "CallQueueLength":
+			e.BeanGauge(c.callQueueLength, bean, attr, port)
+		}
+
+		if m := rpcTiming.FindStringSubmatch(attr); m != nil {
+			switch m[2] {
+			case "NumOps":
+				e.BeanCounter(c.calls, bean, attr, port, rpcPhases[m[1]])
+			case "AvgTime":
+				if v, ok := bean.Float(attr); ok {
+					e.Gauge(c.avgTime, v/1000, port, rpcPhases[m[1]])
+				}
+			}
+		}
+
+		// The percentiles and their count are those of the last interval,
+		// rolled over every interval, so they are gauges.
+		if m := rpcQuantile.FindStringSubmatch(attr); m != nil {
+			percentile, _ := strconv.ParseFloat(m[3], 64)
+			if v, ok := bean.Float(attr); ok {
+				e.Gauge(c.time, v/1000, port, rpcPhases[m[1]], m[2]+"s", strconv.FormatFloat(percentile/100, 'f', -1, 64))
+			}
+		}
+		if m := rpcIntervalOps.FindStringSubmatch(attr); m != nil {
+			e.BeanGauge(c.intervalOps, bean, attr, port, rpcPhases[m[1]], m[2]+"s")
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package lib
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// RulesFlag registers the flag of the rules file on the default flag set.
+func RulesFlag() *string {
+	return flag.String("rules.config.path", "", "YAML file of rules mapping JMX beans to metrics, tried before the built-in rules")
+}
+
+// RulesConfig is the YAML file of rules:
+//
+//	rules:
+//	  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+//	    attribute: 'Capacity(Total|Used|Remaining)'
+//	    name: fsname_system_capacity
+//	    unit: bytes
+//	    labels:
+//	      mode: $1
+type RulesConfig struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule maps attributes of the beans it matches to metrics. Bean, ModelerType
+// and Attribute are regular expressions that must match the whole bean name,
+// modelerType or attribute name. Attributes of composite objects are named
+// with a dot, e.g. HeapMemoryUsage.used.
+//
+// Name, Help and the label values are templates: $1 or ${1} is a group of the
+// Attribute expression, ${group} a named group of any of the expressions,
+// ${attribute} the attribute name, ${key} a key of the ObjectName of the bean
+// such as ${service} or ${name}, and any other ${x} the attribute x of the
+// bean, such as ${tag.port}.
+type Rule struct {
+	Bean        string            `yaml:"bean"`
+	ModelerType string            `yaml:"modelerType"`
+	Attribute   string            `yaml:"attribute"`
+	Name        string            `yaml:"name"`
+	Help        string            `yaml:"help"`
+	Type        string            `yaml:"type"`
+	Unit        string            `yaml:"unit"`
+	Labels      map[string]string `yaml:"labels"`
+	// Values maps the values of a string attribute, such as tag.HAState,
+	// to numbers. Other values of the attribute are skipped.
+	Values map[string]float64 `yaml:"values"`
+	// ValueFactor scales the value, e.g. 0.001 for milliseconds to seconds.
+	ValueFactor float64 `yaml:"valueFactor"`
+	// Optional attributes, such as those of newer Hadoop versions only, are
+	// skipped silently when they are missing or null.
+	Optional bool `yaml:"optional"`
+}
+
+type rule struct {
+	Rule
+	bean        *regexp.Regexp
+	modelerType *regexp.Regexp
+	attribute   *regexp.Regexp
+	literal     string // attribute name when Attribute names a single attribute
+	valueType   prometheus.ValueType
+	labelNames  []string
+}
+
+// Rules turns beans into metrics named <namespace>_<name>. For every
+// attribute of a bean the first rule matching it wins.
+type Rules struct {
+	namespace string
+	rules     []*rule
+
+	mu    sync.Mutex
+	descs map[string]*prometheus.Desc
+}
+
+// LoadRules returns the rules of the file at path, if any, followed by the
+// built-in rules.
+func LoadRules(namespace string, builtin []byte, path string) (*Rules, error) {
+	var configs []RulesConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read rules: %w", err)
+		}
+		var c RulesConfig
+		if err := yaml.UnmarshalStrict(data, &c); err != nil {
+			return nil, fmt.Errorf("invalid rules %s: %w", path, err)
+		}
+		configs = append(configs, c)
+	}
+
+	var c RulesConfig
+	if err := yaml.UnmarshalStrict(builtin, &c); err != nil {
+		return nil, fmt.Errorf("invalid built-in rules: %w", err)
+	}
+	configs = append(configs, c)
+
+	r := &Rules{namespace: namespace, descs: map[string]*prometheus.Desc{}}
+	for _, c := range configs {
+		for i, cfg := range c.Rules {
+			compiled, err := compileRule(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule %d: %w", i+1, err)
+			}
+			r.rules = append(r.rules, compiled)
+		}
+	}
+	return r, nil
+}
+
+func compileRule(cfg Rule) (*rule, error) {
+	r := &rule{Rule: cfg}
+
+	var err error
+	if r.bean, err = compileAnchored(cfg.Bean); err != nil {
+		return nil, fmt.Errorf("bean: %w", err)
+	}
+	if r.modelerType, err = compileAnchored(cfg.ModelerType); err != nil {
+		return nil, fmt.Errorf("modelerType: %w", err)
+	}
+	if cfg.Attribute == "" {
+		return nil, fmt.Errorf("no attribute")
+	}
+	if r.attribute, err = compileAnchored(cfg.Attribute); err != nil {
+		return nil, fmt.Errorf("attribute: %w", err)
+	}
+	// A dot is taken literally in names like tag.HAState or HeapMemoryUsage.used.
+	if strings.ReplaceAll(regexp.QuoteMeta(cfg.Attribute), `\.`, ".") == cfg.Attribute {
+		r.literal = cfg.Attribute
+	} else if prefix, complete := regexp.MustCompile(cfg.Attribute).LiteralPrefix(); complete {
+		r.literal = prefix
+	}
+
+	switch cfg.Type {
+	case "", "gauge":
+		r.valueType = prometheus.GaugeValue
+	case "counter":
+		r.valueType = prometheus.CounterValue
+	case "untyped":
+		r.valueType = prometheus.UntypedValue
+	default:
+		return nil, fmt.Errorf("unknown type %q", cfg.Type)
+	}
+
+	for name := range cfg.Labels {
+		r.labelNames = append(r.labelNames, name)
+	}
+	sort.Strings(r.labelNames)
+
+	return r, nil
+}
+
+func compileAnchored(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// Collect sends the metrics of the beans.
+func (r *Rules) Collect(ch chan<- prometheus.Metric, scrape *Scrape, beans []*Bean) {
+	r.CollectLabels(ch, scrape, beans, nil, nil)
+}
+
+// CollectLabels sends the metrics of the beans with the extra labels added to
+// every metric, e.g. the nn_id of a NameNode of a nameservice.
+func (r *Rules) CollectLabels(ch chan<- prometheus.Metric, scrape *Scrape, beans []*Bean, labelNames, labelValues []string) {
+	c := &collection{ch: ch, seen: map[string]bool{}, labelNames: labelNames, labelValues: labelValues}
+	for _, bean := range beans {
+		scrape.Bean(bean, func() { r.collectBean(c, bean) })
+	}
+}
+
+// collection is the state of one Collect call.
+type collection struct {
+	ch   chan<- prometheus.Metric
+	seen map[string]bool // series sent already
+
+	labelNames  []string
+	labelValues []string
+}
+
+func (r *Rules) collectBean(c *collection, bean *Bean) {
+	var (
+		keys    = objectNameKeys(bean.Name())
+		claimed = map[string]bool{}
+		attrs   []string
+	)
+	for _, rule := range r.rules {
+		groups := map[string]string{}
+		if !matchInto(rule.bean, bean.Name(), groups) || !matchInto(rule.modelerType, bean.ModelerType(), groups) {
+			continue
+		}
+
+		if rule.literal != "" {
+			if !claimed[rule.literal] {
+				claimed[rule.literal] = true
+				if rule.Optional && !bean.Has(rule.literal) {
+					continue
+				}
+				r.collectAttribute(c, rule, bean, rule.literal, keys, groups, true)
+			}
+			continue
+		}
+
+		if attrs == nil {
+			attrs = bean.flatten()
+		}
+		for _, attr := range attrs {
+			if claimed[attr] {
+				continue
+			}
+			if rule.attribute.MatchString(attr) {
+				claimed[attr] = true
+				r.collectAttribute(c, rule, bean, attr, keys, groups, false)
+			}
+		}
+	}
+}
+
+// collectAttribute sends the metric of the attribute. Problems with an
+// attribute named by a rule are reported; attributes picked by a pattern
+// that turn out not to be numbers are skipped silently.
+func (r *Rules) collectAttribute(c *collection, rule *rule, bean *Bean, attr string, keys, groups map[string]string, report bool) {
+	holder, field := bean.field(attr)
+
+	var (
+		value float64
+		ok    bool
+	)
+	switch {
+	case rule.Values != nil:
+		var s string
+		if report {
+			s, ok = holder.String(field)
+		} else {
+			s, ok = holder.attrs[field].(string)
+		}
+		if ok {
+			value, ok = rule.Values[s]
+		}
+	case report:
+		value, ok = holder.Float(field)
+	default:
+		// Strings picked by a pattern are tags such as tag.port, not values.
+		if _, isString := holder.attrs[field].(string); !isString {
+			value, ok = number(holder.attrs[field])
+		}
+	}
+	if !ok {
+		return
+	}
+	if rule.ValueFactor != 0 {
+		value *= rule.ValueFactor
+	}
+
+	groups = copyGroups(groups)
+	if m := rule.attribute.FindStringSubmatch(attr); m != nil {
+		for i, name := range rule.attribute.SubexpNames() {
+			if i > 0 {
+				groups[fmt.Sprint(i)] = m[i]
+			}
+			if name != "" {
+				groups[name] = m[i]
+			}
+		}
+	}
+	groups["attribute"] = attr
+	x := &expansion{bean: bean, keys: keys, groups: groups}
+
+	name := x.expand(rule.Name)
+	if rule.Name == "" {
+		name = defaultMetricName(bean, keys, attr)
+	}
+	name = sanitizeName(name)
+	if rule.Unit != "" && !strings.HasSuffix(name, "_"+rule.Unit) {
+		name += "_" + rule.Unit
+	}
+
+	help := x.expand(rule.Help)
+	if rule.Help == "" {
+		help = attr + " of " + bean.Name()
+	}
+
+	labelNames := append(rule.labelNames[:len(rule.labelNames):len(rule.labelNames)], c.labelNames...)
+	labelValues := make([]string, 0, len(labelNames))
+	for _, label := range rule.labelNames {
+		labelValues = append(labelValues, x.expand(rule.Labels[label]))
+	}
+	labelValues = append(labelValues, c.labelValues...)
+	if x.failed {
+		return
+	}
+
+	desc := r.desc(name, help, labelNames)
+	series := desc.String() + "\x00" + strings.Join(labelValues, "\x00")
+	if c.seen[series] {
+		return
+	}
+	c.seen[series] = true
+
+	c.ch <- prometheus.MustNewConstMetric(desc, rule.valueType, value, labelValues...)
+}
+
+// desc returns the descriptor of the metric. The first help seen for a
+// metric name is kept so all series of a metric agree.
+func (r *Rules) desc(name, help string, labels []string) *prometheus.Desc {
+	key := name + "\x00" + strings.Join(labels, "\x00")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d, ok := r.descs[key]; ok {
+		return d
+	}
+	d := prometheus.NewDesc(prometheus.BuildFQName(r.namespace, "", name), help, labels, nil)
+	r.descs[key] = d
+	return d
+}
+
+type expansion struct {
+	bean   *Bean
+	keys   map[string]string
+	groups map[string]string
+	failed bool
+}
+
+var templateVar = regexp.MustCompile(`\$(?:\{([^}]+)\}|([A-Za-z0-9_]+))`)
+
+// expand replaces the variables of the template. An unknown variable is
+// reported as an attribute error of the bean and fails the expansion.
+func (x *expansion) expand(template string) string {
+	return templateVar.ReplaceAllStringFunc(template, func(v string) string {
+		m := templateVar.FindStringSubmatch(v)
+		name := m[1] + m[2]
+		if s, ok := x.groups[name]; ok {
+			return s
+		}
+		if s, ok := x.keys[name]; ok {
+			return s
+		}
+		holder, field := x.bean.field(name)
+		s, ok := holder.String(field)
+		if !ok {
+			x.failed = true
+		}
+		return s
+	})
+}
+
+func matchInto(re *regexp.Regexp, s string, groups map[string]string) bool {
+	if re == nil {
+		return true
+	}
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = m[i]
+		}
+	}
+	return true
+}
+
+func copyGroups(groups map[string]string) map[string]string {
+	c := make(map[string]string, len(groups)+4)
+	for k, v := range groups {
+		c[k] = v
+	}
+	return c
+}
+
+// objectNameKeys returns the keys of an ObjectName such as
+// "Hadoop:service=NameNode,name=FSNamesystem".
+func objectNameKeys(name string) map[string]string {
+	keys := map[string]string{}
+	i := strings.Index(name, ":")
+	if i < 0 {
+		return keys
+	}
+	for _, kv := range strings.Split(name[i+1:], ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			keys[k] = strings.Trim(v, `"`)
+		}
+	}
+	return keys
+}
+
+// defaultMetricName follows the <bean>_<metric> convention, the bean being
+// the name or type key of the ObjectName, e.g. fs_namesystem_missing_blocks.
+func defaultMetricName(bean *Bean, keys map[string]string, attr string) string {
+	b := keys["name"]
+	if b == "" {
+		b = keys["type"]
+	}
+	if b == "" {
+		b = bean.Name()
+	}
+	return SnakeCase(b) + "_" + SnakeCase(attr)
+}
+
+// SnakeCase converts a camelCase JMX name to lower case words separated by
+// underscores: "GcTimeMillisParNew" becomes "gc_time_millis_par_new" and
+// "HeapMemoryUsage.used" becomes "heap_memory_usage_used".
+func SnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteByte('_')
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.Trim(underscores.ReplaceAllString(b.String(), "_"), "_")
+}
+
+var (
+	underscores      = regexp.MustCompile(`_+`)
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+)
+
+func sanitizeName(name string) string {
+	return invalidNameChars.ReplaceAllString(name, "_")
+}
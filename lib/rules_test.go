@@ -0,0 +1,369 @@
+package lib
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	descFields     = regexp.MustCompile(`fqName: "([^"]*)", help: "([^"]*)"`)
+	attributeLabel = regexp.MustCompile(`attribute="([^"]*)"`)
+)
+
+// series returns the metric as name{label="value",...}, its help and its
+// value.
+func series(t *testing.T, m prometheus.Metric) (string, string, float64) {
+	t.Helper()
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	fields := descFields.FindStringSubmatch(m.Desc().String())
+	if fields == nil {
+		t.Fatalf("unexpected descriptor %s", m.Desc())
+	}
+
+	var labels []string
+	for _, l := range pb.GetLabel() {
+		labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+	}
+	sort.Strings(labels)
+	name := fields[1] + "{" + strings.Join(labels, ",") + "}"
+
+	switch {
+	case pb.Gauge != nil:
+		return name, fields[2], pb.GetGauge().GetValue()
+	case pb.Counter != nil:
+		return name, fields[2], pb.GetCounter().GetValue()
+	}
+	return name, fields[2], pb.GetUntyped().GetValue()
+}
+
+// collectRules returns the series the rules make of the beans of the /jmx
+// response, the help of their metrics and the attribute errors by attribute.
+func collectRules(t *testing.T, config, jmx string) (values map[string]float64, help map[string]string, errors map[string]float64) {
+	t.Helper()
+	r, err := LoadRules("hdfs_namenode", []byte(config), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	beans, err := ParseBeans([]byte(jmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testScrape()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		r.Collect(ch, s, beans)
+		s.metrics.attributeErrors.Collect(ch)
+		close(ch)
+	}()
+
+	values, help, errors = map[string]float64{}, map[string]string{}, map[string]float64{}
+	for m := range ch {
+		name, h, v := series(t, m)
+		if strings.HasPrefix(name, "hadoop_exporter_attribute_errors_total{") {
+			errors[attributeLabel.FindStringSubmatch(name)[1]] = v
+			continue
+		}
+		if _, ok := values[name]; ok {
+			t.Errorf("series %s sent twice", name)
+		}
+		values[name] = v
+		help[name[:strings.Index(name, "{")]] = h
+	}
+	return values, help, errors
+}
+
+func TestRules(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		rules  string
+		jmx    string
+		want   map[string]float64
+		errors map[string]float64
+	}{
+		{
+			name: "capture group label and unit",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: 'Capacity(Total|Used)'
+    name: fsname_system_capacity
+    unit: bytes
+    labels:
+      mode: $1`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","CapacityTotal":100,"CapacityUsed":40,"CapacityRemaining":60}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_fsname_system_capacity_bytes{mode="Total"}`: 100,
+				`hdfs_namenode_fsname_system_capacity_bytes{mode="Used"}`:  40,
+			},
+		},
+		{
+			name: "named group of the bean and braced capture group",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=RpcActivityForPort(?P<port>\d+)'
+    attribute: '(Received|Sent)Bytes'
+    name: rpc_activity_bytes_total
+    type: counter
+    labels:
+      port: ${port}
+      direction: ${1}`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=RpcActivityForPort8020","ReceivedBytes":10,"SentBytes":20}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_activity_bytes_total{direction="Received",port="8020"}`: 10,
+				`hdfs_namenode_rpc_activity_bytes_total{direction="Sent",port="8020"}`:     20,
+			},
+		},
+		{
+			name: "attribute and object name key labels",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=RpcActivityForPort\d+'
+    attribute: CallQueueLength
+    name: rpc_activity_call_queue_length
+    labels:
+      port: ${tag.port}
+      bean: ${name}
+      attr: ${attribute}`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=RpcActivityForPort8020","tag.port":"8020","CallQueueLength":3}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_activity_call_queue_length{attr="CallQueueLength",bean="RpcActivityForPort8020",port="8020"}`: 3,
+			},
+		},
+		{
+			name: "composite attributes",
+			rules: `rules:
+  - bean: 'java.lang:type=Memory'
+    attribute: 'HeapMemoryUsage\.(used|max)'
+    name: memory_heap
+    unit: bytes
+    labels:
+      area: $1
+  - bean: 'java.lang:type=Memory'
+    attribute: HeapMemoryUsage.committed
+    name: memory_heap_committed_bytes`,
+			jmx: `{"beans":[{"name":"java.lang:type=Memory","HeapMemoryUsage":{"used":100,"max":200,"committed":150}}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_memory_heap_bytes{area="used"}`: 100,
+				`hdfs_namenode_memory_heap_bytes{area="max"}`:  200,
+				`hdfs_namenode_memory_heap_committed_bytes{}`:  150,
+			},
+		},
+		{
+			name: "values of a string attribute",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: tag.HAState
+    name: fsname_system_ha_state_active
+    values:
+      active: 1
+      standby: 0`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","tag.HAState":"active"},{"name":"Hadoop:service=NameNode,name=FSNamesystem","tag.HAState":"initializing"}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_fsname_system_ha_state_active{}`: 1,
+			},
+		},
+		{
+			name: "value factor",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=NameNodeActivity'
+    attribute: EditLogTailTimeAvgTime
+    name: edit_log_tail_avg_time_seconds
+    valueFactor: 0.001`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=NameNodeActivity","EditLogTailTimeAvgTime":1500}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_edit_log_tail_avg_time_seconds{}`: 1.5,
+			},
+		},
+		{
+			name: "first matching rule wins",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: MissingBlocks
+    name: missing_blocks
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: 'Missing(.*)'
+    name: missing
+    labels:
+      kind: $1`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","MissingBlocks":2,"MissingReplOneBlocks":1}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_missing_blocks{}`:              2,
+				`hdfs_namenode_missing{kind="ReplOneBlocks"}`: 1,
+			},
+		},
+		{
+			name: "default name and strings skipped by patterns",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: '.*'`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","modelerType":"FSNamesystem","tag.Context":"dfs","BlocksTotal":12,"CorruptBlocks":null}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_fs_namesystem_blocks_total{}`: 12,
+			},
+		},
+		{
+			name: "modelerType",
+			rules: `rules:
+  - modelerType: 'RpcActivityForPort(?P<port>\d+)'
+    attribute: NumOpenConnections
+    name: rpc_activity_open_connections
+    labels:
+      port: ${port}`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=RpcActivityForPort8020","modelerType":"RpcActivityForPort8020","NumOpenConnections":5},{"name":"Hadoop:service=NameNode,name=RpcDetailedActivityForPort8020","modelerType":"RpcDetailedActivityForPort8020","NumOpenConnections":5}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_activity_open_connections{port="8020"}`: 5,
+			},
+		},
+		{
+			name: "missing attributes",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: LockQueueLength
+    name: fsname_system_lock_queue_length
+    optional: true
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: BlocksTotal
+    name: fsname_system_blocks
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: tag.HAState
+    name: fsname_system_ha_state_active
+    values:
+      active: 1`,
+			jmx:  `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","tag.HAState":null}]}`,
+			want: map[string]float64{},
+			errors: map[string]float64{
+				"BlocksTotal": 1,
+				"tag.HAState": 1,
+			},
+		},
+		{
+			name: "unknown label variable",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: BlocksTotal
+    name: fsname_system_blocks
+    labels:
+      context: ${tag.Context}`,
+			jmx:  `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","BlocksTotal":12}]}`,
+			want: map[string]float64{},
+			errors: map[string]float64{
+				"tag.Context": 1,
+			},
+		},
+		{
+			name: "series sent once",
+			rules: `rules:
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem.*'
+    attribute: BlocksTotal
+    name: fsname_system_blocks`,
+			jmx: `{"beans":[{"name":"Hadoop:service=NameNode,name=FSNamesystem","BlocksTotal":12},{"name":"Hadoop:service=NameNode,name=FSNamesystemState","BlocksTotal":13}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_fsname_system_blocks{}`: 12,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _, errors := collectRules(t, tt.rules, tt.jmx)
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("series = %v, want %v", values, tt.want)
+			}
+			if tt.errors == nil {
+				tt.errors = map[string]float64{}
+			}
+			if !reflect.DeepEqual(errors, tt.errors) {
+				t.Errorf("attribute errors = %v, want %v", errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestRulesHelp(t *testing.T) {
+	values, help, _ := collectRules(t, `rules:
+  - bean: 'Hadoop:service=NameNode,name=RpcActivityForPort(\d+)'
+    attribute: CallQueueLength
+    name: rpc_activity_call_queue_length
+    help: 'Current length of the call queue of ${name}'
+    labels:
+      port: ${tag.port}
+  - bean: 'Hadoop:service=NameNode,name=FSNamesystem'
+    attribute: BlocksTotal`,
+		`{"beans":[
+			{"name":"Hadoop:service=NameNode,name=RpcActivityForPort8020","tag.port":"8020","CallQueueLength":3},
+			{"name":"Hadoop:service=NameNode,name=RpcActivityForPort8060","tag.port":"8060","CallQueueLength":1},
+			{"name":"Hadoop:service=NameNode,name=FSNamesystem","BlocksTotal":12}
+		]}`)
+
+	if len(values) != 3 {
+		t.Errorf("series = %v, want 3 series", values)
+	}
+	for name, want := range map[string]string{
+		// The help of the first series is kept for all series of the metric.
+		"hdfs_namenode_rpc_activity_call_queue_length": "Current length of the call queue of RpcActivityForPort8020",
+		"hdfs_namenode_fs_namesystem_blocks_total":     "BlocksTotal of Hadoop:service=NameNode,name=FSNamesystem",
+	} {
+		if help[name] != want {
+			t.Errorf("help of %s = %q, want %q", name, help[name], want)
+		}
+	}
+}
+
+func TestRulesDesc(t *testing.T) {
+	r := &Rules{namespace: "hdfs_namenode", descs: map[string]*prometheus.Desc{}}
+
+	d := r.desc("rpc_activity_call_queue_length", "first", []string{"port"})
+	if again := r.desc("rpc_activity_call_queue_length", "second", []string{"port"}); again != d {
+		t.Errorf("desc of the same name and labels = %s, want the cached %s", again, d)
+	}
+	if other := r.desc("rpc_activity_call_queue_length", "second", []string{"port", "nn_id"}); other == d {
+		t.Errorf("desc with other labels is the cached %s", d)
+	} else if !strings.Contains(other.String(), `help: "second"`) {
+		t.Errorf("desc with other labels = %s, want help second", other)
+	}
+	if other := r.desc("rpc_activity_open_connections", "first", []string{"port"}); other == d {
+		t.Errorf("desc of another name is the cached %s", d)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		rules string
+		err   string
+	}{
+		{rules: "rules:\n  - bean: 'x'\n", err: "invalid rule 1: no attribute"},
+		{rules: "rules:\n  - attribute: '('\n", err: "invalid rule 1: attribute"},
+		{rules: "rules:\n  - attribute: x\n  - bean: '('\n    attribute: x\n", err: "invalid rule 2: bean"},
+		{rules: "rules:\n  - attribute: x\n    type: summary\n", err: `invalid rule 1: unknown type "summary"`},
+		{rules: "rules:\n  - attribute: x\n    lables: {}\n", err: "invalid built-in rules"},
+	} {
+		_, err := LoadRules("hdfs_namenode", []byte(tt.rules), "")
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("LoadRules(%q) error = %v, want %s...", tt.rules, err, tt.err)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{in: "GcTimeMillisParNew", want: "gc_time_millis_par_new"},
+		{in: "HeapMemoryUsage.used", want: "heap_memory_usage_used"},
+		{in: "FSNamesystem", want: "fs_namesystem"},
+		{in: "NumLiveDataNodes", want: "num_live_data_nodes"},
+		{in: "RpcActivityForPort8020", want: "rpc_activity_for_port8020"},
+		{in: "tag.HAState", want: "tag_ha_state"},
+		{in: "Code Cache", want: "code_cache"},
+	} {
+		if got := SnakeCase(tt.in); got != tt.want {
+			t.Errorf("SnakeCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
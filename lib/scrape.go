@@ -0,0 +1,249 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/log"
+)
+
+// Stages of a scrape, used as the stage label of the scrape error counter.
+const (
+	StageFetch = "fetch"
+	StageAuth  = "auth"
+	StageParse = "parse"
+	// StageJMX is the fetch or parse of the /jmx beans of a role whose main
+	// metrics come from elsewhere, such as the ResourceManager. Its errors do
+	// not fail the scrape.
+	StageJMX = "jmx"
+)
+
+var (
+	upDesc             = NewMetricDesc("hadoop_exporter", "", "up", "Whether the last scrape of the Hadoop daemon was successful", "target")
+	scrapeDurationDesc = NewMetricDesc("hadoop_exporter", "", "scrape_duration_seconds", "Duration of the last scrape of the Hadoop daemon", "target")
+
+	// defaultScrapeMetrics holds the scrapes of the daemons of the exporter.
+	defaultScrapeMetrics = newScrapeMetrics(&attributeLog{byTarget: true})
+	// probeAttributeLog is shared by the probes, each of which has scrape
+	// metrics of its own.
+	probeAttributeLog = &attributeLog{}
+)
+
+// scrapeMetrics holds the error counters and last success times of scrapes,
+// by target. Probes, whose targets are not known in advance, count theirs
+// apart in every request so they are not kept once the request is served.
+type scrapeMetrics struct {
+	errors          *prometheus.CounterVec
+	lastSuccess     *prometheus.GaugeVec
+	beanParseErrors *prometheus.CounterVec
+	attributeErrors *prometheus.CounterVec
+
+	logged *attributeLog
+}
+
+func newScrapeMetrics(logged *attributeLog) *scrapeMetrics {
+	return &scrapeMetrics{
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hadoop_exporter",
+			Name:      "scrape_errors_total",
+			Help:      "Total number of failed scrapes of the Hadoop daemon by the stage that failed",
+		}, []string{"target", "stage"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "hadoop_exporter",
+			Name:      "last_successful_scrape_timestamp_seconds",
+			Help:      "Time of the last successful scrape of the Hadoop daemon",
+		}, []string{"target"}),
+		beanParseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hadoop_exporter",
+			Name:      "bean_parse_errors_total",
+			Help:      "Total number of beans that could not be parsed",
+		}, []string{"target", "bean"}),
+		attributeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hadoop_exporter",
+			Name:      "attribute_errors_total",
+			Help:      "Total number of bean attributes that were missing, null or of an unexpected type",
+		}, []string{"target", "bean", "attribute"}),
+		logged: logged,
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *scrapeMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.errors.Describe(ch)
+	m.lastSuccess.Describe(ch)
+	m.beanParseErrors.Describe(ch)
+	m.attributeErrors.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *scrapeMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.errors.Collect(ch)
+	m.lastSuccess.Collect(ch)
+	m.beanParseErrors.Collect(ch)
+	m.attributeErrors.Collect(ch)
+}
+
+// attributeLog holds the attributes whose error was logged already, so a
+// daemon lacking an attribute does not flood the log. Unless byTarget is set
+// an attribute is logged once whatever the target, which keeps the log of
+// the probes bounded by the beans of Hadoop rather than by their targets.
+type attributeLog struct {
+	byTarget bool
+	logged   sync.Map
+}
+
+// first tells whether the error of the attribute is seen for the first time.
+func (l *attributeLog) first(target, bean, attr string) bool {
+	key := bean + "\x00" + attr
+	if l.byTarget {
+		key = target + "\x00" + key
+	}
+	_, logged := l.logged.LoadOrStore(key, true)
+	return !logged
+}
+
+type scrapeMetricsKey struct{}
+
+// withScrapeMetrics returns a context whose scrapes are counted in m rather
+// than in the metrics of the exporter.
+func withScrapeMetrics(ctx context.Context, m *scrapeMetrics) context.Context {
+	return context.WithValue(ctx, scrapeMetricsKey{}, m)
+}
+
+// ScrapeCollector exports the error counters and last success times of the
+// scrapes of the daemons of the exporter. The up and duration of a scrape are
+// sent by Scrape.Done, and probes send their own.
+type ScrapeCollector struct{}
+
+// Describe implements the prometheus.Collector interface.
+func (ScrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	defaultScrapeMetrics.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (ScrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	defaultScrapeMetrics.Collect(ch)
+}
+
+// DescribeScrape sends the descriptors of the metrics sent by Scrape.Done,
+// without the extra labels of Scrape.Emit.
+func DescribeScrape(ch chan<- *prometheus.Desc) {
+	ch <- upDesc.desc(nil)
+	ch <- scrapeDurationDesc.desc(nil)
+}
+
+// Scrape records the outcome of one scrape of a target.
+type Scrape struct {
+	target  string
+	start   time.Time
+	failed  bool
+	metrics *scrapeMetrics
+}
+
+// BeginScrape starts timing a scrape of the target. Its errors are counted in
+// the metrics of the probe of ctx, if any, or else in those of ScrapeCollector.
+func BeginScrape(ctx context.Context, target string) *Scrape {
+	metrics, ok := ctx.Value(scrapeMetricsKey{}).(*scrapeMetrics)
+	if !ok {
+		metrics = defaultScrapeMetrics
+	}
+	return &Scrape{target: target, start: time.Now(), metrics: metrics}
+}
+
+// Fail logs the error and marks the scrape as failed in the stage.
+func (s *Scrape) Fail(stage string, err error) {
+	s.Error(stage, err)
+	s.failed = true
+}
+
+// Error logs and counts the error of the stage without failing the scrape,
+// for a stage whose metrics the others do without.
+func (s *Scrape) Error(stage string, err error) {
+	log.Errorf("failed to scrape %s (%s): %v", s.target, stage, err)
+	s.metrics.errors.WithLabelValues(s.target, stage).Inc()
+}
+
+// FailFetch marks the scrape as failed with an error of JmxClient.Fetch,
+// telling authentication errors apart from other errors of the request.
+func (s *Scrape) FailFetch(err error) {
+	s.Fail(FetchStage(err), err)
+}
+
+// Bean runs parse on the bean. Attribute errors met by the accessors of the
+// bean are counted per attribute. A panic of parse is counted as a parse
+// error of the bean instead of failing the whole scrape.
+func (s *Scrape) Bean(b *Bean, parse func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("failed to parse bean %s of %s: %v", b.name, s.target, r)
+			s.metrics.beanParseErrors.WithLabelValues(s.target, b.name).Inc()
+		}
+	}()
+
+	b.scrape = s
+	parse()
+}
+
+// Derived runs collect, which derives metrics from several beans. A panic of
+// collect is logged instead of failing the whole scrape.
+func (s *Scrape) Derived(collect func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("failed to derive metrics of %s: %v", s.target, r)
+		}
+	}()
+
+	collect()
+}
+
+// attributeError counts an error of the attribute of the bean, and logs it
+// the first time it is seen.
+func (s *Scrape) attributeError(bean, attr, problem string) {
+	s.metrics.attributeErrors.WithLabelValues(s.target, bean, attr).Inc()
+	if s.metrics.logged.first(s.target, bean, attr) {
+		log.Errorf("skipping attribute %s of bean %s of %s: %s", attr, bean, s.target, problem)
+	}
+}
+
+// Done sends the up and duration metrics of the scrape.
+func (s *Scrape) Done(ch chan<- prometheus.Metric) {
+	s.Emit(NewEmitter(ch, nil, nil))
+}
+
+// Emit sends the up and duration metrics of the scrape through e, with the
+// extra labels of the other metrics of the target.
+func (s *Scrape) Emit(e *Emitter) {
+	up := 1.0
+	if s.failed {
+		up = 0
+	} else {
+		s.metrics.lastSuccess.WithLabelValues(s.target).Set(float64(time.Now().Unix()))
+	}
+
+	e.Gauge(upDesc, up, s.target)
+	e.Gauge(scrapeDurationDesc, time.Since(s.start).Seconds(), s.target)
+}
+
+// FetchStage returns StageAuth for authentication errors and StageFetch for
+// any other error of a request.
+func FetchStage(err error) string {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden {
+			return StageAuth
+		}
+		return StageFetch
+	}
+
+	for _, authErr := range []error{ErrKrb5Config, ErrBadKeytab, ErrKDCUnreachable, ErrKrb5Login, ErrSPNEGORejected} {
+		if errors.Is(err, authErr) {
+			return StageAuth
+		}
+	}
+
+	return StageFetch
+}
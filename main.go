@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/meoww-bot/hadoop_exporter/datanode"
+	"github.com/meoww-bot/hadoop_exporter/journalnode"
+	"github.com/meoww-bot/hadoop_exporter/lib"
+	"github.com/meoww-bot/hadoop_exporter/namenode"
+	"github.com/meoww-bot/hadoop_exporter/resourcemanager"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/log"
+)
+
+const (
+	// detectInterval is the wait between attempts to detect the role of
+	// the daemon in auto mode.
+	detectInterval = 10 * time.Second
+	// probeListenAddress is the default listen address in probe mode.
+	probeListenAddress = ":9097"
+)
+
+var roles = []*lib.Role{namenode.Role, datanode.Role, journalnode.Role, resourcemanager.Role}
+
+// options are the flags shared by all subcommands.
+type options struct {
+	listenAddress *string
+	metricsPath   *string
+	daemonURL     *string
+	legacyURL     *string
+	krb5Auth      *lib.Krb5Auth
+	jmxOptions    *lib.JmxOptions
+	rulesPath     *string
+	probePath     *string
+}
+
+// registerFlags registers the flags of the subcommand on the default flag
+// set, with the defaults of the role. In auto mode role is nil and the
+// defaults follow the detected role. The probe subcommand scrapes no daemon
+// of its own.
+func registerFlags(name string, role *lib.Role) *options {
+	listenAddress, listenHelp := "", "Address on which to expose metrics and web interface."
+	switch {
+	case role != nil:
+		listenAddress = role.ListenAddress
+	case name == "probe":
+		listenAddress = probeListenAddress
+	default:
+		listenHelp += " (default the address of the detected role)"
+	}
+
+	o := &options{
+		listenAddress: flag.String("web.listen-address", listenAddress, listenHelp),
+		metricsPath:   flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics."),
+		krb5Auth:      lib.Krb5AuthFlags(),
+		jmxOptions:    lib.JmxOptionsFlags(),
+		rulesPath:     lib.RulesFlag(),
+		probePath:     flag.String("probe.config.path", "", "YAML file of the modules of /probe in addition to one per role"),
+	}
+	switch {
+	case role != nil:
+		help := "Base URL of the Hadoop " + role.Title + "."
+		if role.NewGroupExporter != nil {
+			help += " Several comma separated URLs, each optionally named as in nn1=http://nn01:9870, are scraped together."
+		}
+		o.daemonURL = flag.String("daemon.url", role.URL, help)
+		o.legacyURL = flag.String(role.LegacyURLFlag, "", "Deprecated: use -daemon.url.")
+	case name == "auto":
+		o.daemonURL = flag.String("daemon.url", "", "Base URL of the Hadoop daemon, e.g. http://localhost:9870")
+	}
+
+	// The probe and auto modes may scrape daemons of any role.
+	for _, r := range roles {
+		if (role == nil || r == role) && r.RegisterFlags != nil {
+			r.RegisterFlags()
+		}
+	}
+	return o
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <role> [flags]\n\nRoles:\n", os.Args[0])
+	for _, role := range roles {
+		fmt.Fprintf(os.Stderr, "  %-16s export the metrics of a %s\n", role.Name, role.Title)
+	}
+	fmt.Fprintf(os.Stderr, "  %-16s detect the role from the beans of the daemon at -daemon.url\n", "auto")
+	fmt.Fprintf(os.Stderr, "  %-16s only scrape the daemons asked for through /probe\n", "probe")
+	fmt.Fprintf(os.Stderr, "\nRun %s <role> -h for the flags of a role.\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	var role *lib.Role
+	for _, r := range roles {
+		if r.Name == name {
+			role = r
+		}
+	}
+	if role == nil && name != "auto" && name != "probe" {
+		if name != "-h" && name != "-help" && name != "--help" && name != "help" {
+			fmt.Fprintf(os.Stderr, "unknown role %q\n\n", name)
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	flag.CommandLine.Init(os.Args[0]+" "+name, flag.ExitOnError)
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s %s:\n", os.Args[0], name)
+		flag.PrintDefaults()
+	}
+	o := registerFlags(name, role)
+	flag.CommandLine.Parse(os.Args[2:])
+
+	modules, err := lib.LoadModules(*o.probePath, roles, *o.krb5Auth, *o.rulesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/probe", lib.ProbeHandler(modules, *o.jmxOptions))
+	prometheus.MustRegister(lib.KerberosCollector{}, lib.ScrapeCollector{})
+
+	title := "Hadoop"
+	if name == "probe" {
+		http.Handle(*o.metricsPath, promhttp.Handler())
+	} else {
+		role = daemonRole(role, o)
+		title = role.Title
+	}
+	if *o.listenAddress == "" {
+		*o.listenAddress = role.ListenAddress
+	}
+
+	log.Printf("Starting Server: %s", *o.listenAddress)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>
+        <head><title>` + title + ` Exporter</title></head>
+        <body>
+        <h1>` + title + ` Exporter</h1>
+        <p><a href="` + *o.metricsPath + `">Metrics</a></p>
+        </body>
+        </html>`))
+	})
+	err = http.ListenAndServe(*o.listenAddress, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// daemonRole sets up the metrics of the daemon at -daemon.url, detecting its
+// role in auto mode, and returns the role.
+func daemonRole(role *lib.Role, o *options) *lib.Role {
+	urls := *o.daemonURL
+	if role != nil && *o.legacyURL != "" {
+		log.Printf("-%s is deprecated, use -daemon.url", role.LegacyURLFlag)
+		urls = strings.TrimSuffix(strings.TrimSuffix(*o.legacyURL, "/"), role.Path)
+	}
+	daemons := parseDaemonURLs(urls)
+	if len(daemons) == 0 {
+		log.Fatal("-daemon.url is required")
+	}
+
+	if role == nil {
+		role = detectRole(daemons[0].url, o)
+		log.Printf("Detected role %s at %s", role.Name, daemons[0].url)
+	}
+
+	rules, err := lib.LoadRules(role.Namespace, role.Rules, *o.rulesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var exporter lib.ContextCollector
+	if len(daemons) == 1 && daemons[0].id == "" {
+		exporter = role.NewExporter(lib.NewJmxClient(daemons[0].url+role.Path, *o.krb5Auth, *o.jmxOptions), rules)
+	} else {
+		if role.NewGroupExporter == nil {
+			log.Fatalf("the %s role takes a single -daemon.url", role.Name)
+		}
+		group := make([]lib.Daemon, len(daemons))
+		for i, d := range daemons {
+			client := lib.NewJmxClient(d.url+role.Path, *o.krb5Auth, *o.jmxOptions)
+			group[i] = lib.Daemon{ID: d.id, Client: client}
+			if group[i].ID == "" {
+				group[i].ID = client.Target()
+			}
+		}
+		exporter = role.NewGroupExporter(group, rules)
+	}
+	http.Handle(*o.metricsPath, lib.MetricsHandler(exporter))
+	return role
+}
+
+type daemonURL struct {
+	id  string
+	url string
+}
+
+// parseDaemonURLs parses a comma separated list of URLs, each optionally
+// preceded by an id, e.g. "nn1=http://nn01:9870,nn2=http://nn02:9870". A URL
+// without a scheme is taken as http, and a URL given again is dropped.
+func parseDaemonURLs(s string) []daemonURL {
+	var daemons []daemonURL
+	seen := map[string]bool{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var d daemonURL
+		if i := strings.Index(item, "="); i >= 0 && !strings.Contains(item[:i], "/") {
+			d.id, item = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+		}
+		if !strings.Contains(item, "://") {
+			item = "http://" + item
+		}
+		d.url = strings.TrimSuffix(item, "/")
+		if seen[d.url] {
+			continue
+		}
+		seen[d.url] = true
+		daemons = append(daemons, d)
+	}
+	return daemons
+}
+
+// detectRole fetches the /jmx of the daemon at url until its beans tell its
+// role. The daemon may still be starting, so failures are retried.
+func detectRole(url string, o *options) *lib.Role {
+	client := lib.NewJmxClient(url+"/jmx", *o.krb5Auth, *o.jmxOptions)
+	for {
+		role, err := fetchRole(client)
+		if err == nil {
+			return role
+		}
+		log.Errorf("failed to detect the role of %s, retrying in %s: %v", url, detectInterval, err)
+		time.Sleep(detectInterval)
+	}
+}
+
+func fetchRole(client *lib.JmxClient) (*lib.Role, error) {
+	data, err := client.Fetch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	beans, err := lib.ParseBeans(data)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := lib.DetectRole(beans, roles)
+	if !ok {
+		return nil, fmt.Errorf("no beans of a known Hadoop service")
+	}
+	return role, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDaemonURLs(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want []daemonURL
+	}{
+		{s: "", want: nil},
+		{s: " , ,", want: nil},
+		{s: "http://localhost:9870", want: []daemonURL{{url: "http://localhost:9870"}}},
+		{s: "https://nn01:9871/", want: []daemonURL{{url: "https://nn01:9871"}}},
+		{
+			s:    "nn1=http://nn01:9870,nn2=http://nn02:9870",
+			want: []daemonURL{{id: "nn1", url: "http://nn01:9870"}, {id: "nn2", url: "http://nn02:9870"}},
+		},
+		{
+			s:    " nn1 = http://nn01:9870 ,\tnn2=http://nn02:9870\n",
+			want: []daemonURL{{id: "nn1", url: "http://nn01:9870"}, {id: "nn2", url: "http://nn02:9870"}},
+		},
+		{
+			s:    "http://nn01:9870,http://nn01:9870/,nn3=http://nn01:9870",
+			want: []daemonURL{{url: "http://nn01:9870"}},
+		},
+		{
+			s:    "nn01:9870,nn2=nn02:9870",
+			want: []daemonURL{{url: "http://nn01:9870"}, {id: "nn2", url: "http://nn02:9870"}},
+		},
+		{
+			s:    "http://nn01:9870/jmx?qry=a=b",
+			want: []daemonURL{{url: "http://nn01:9870/jmx?qry=a=b"}},
+		},
+	} {
+		if got := parseDaemonURLs(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDaemonURLs(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package namenode
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+// rpcUserLimit is the maximum number of users, by open connections or call
+// volume, with per-user RPC metrics.
+var rpcUserLimit = 10
+
+var (
+	rpcUserConnectionsDesc   = lib.NewMetricDesc(namespace, "rpc", "user_open_connections", "Current number of open RPC connections of the user", "port", "user")
+	rpcUserCallVolumeDesc    = lib.NewMetricDesc(namespace, "rpc", "user_call_volume", "Decayed call volume of the user in the DecayRpcScheduler", "port", "user")
+	rpcUserPriorityDesc      = lib.NewMetricDesc(namespace, "rpc", "user_priority", "Priority level the DecayRpcScheduler schedules the calls of the user with, 0 being the highest", "port", "user")
+	rpcUniqueCallersDesc     = lib.NewMetricDesc(namespace, "rpc", "scheduler_unique_callers", "Current number of unique callers known to the DecayRpcScheduler", "port")
+	rpcTotalCallVolumeDesc   = lib.NewMetricDesc(namespace, "rpc", "scheduler_call_volume", "Decayed call volume of all users in the DecayRpcScheduler", "port")
+	rpcPriorityCallsDesc     = lib.NewMetricDesc(namespace, "rpc", "priority_completed_call_volume", "Number of calls of the priority level completed in the last window", "port", "priority")
+	rpcPriorityResponseDesc  = lib.NewMetricDesc(namespace, "rpc", "priority_avg_response_time_seconds", "Average response time of the calls of the priority level in the last window", "port", "priority")
+	rpcCallQueueLengthDesc   = lib.NewMetricDesc(namespace, "rpc", "call_queue_length", "Current length of the call queue of the priority level", "port", "priority")
+	rpcCallQueueOverflowDesc = lib.NewMetricDesc(namespace, "rpc", "call_queue_overflowed_calls_total", "Total number of calls that overflowed the call queue of the priority level", "port", "priority")
+)
+
+var (
+	// ipcPort finds the port in the names of the beans of the FairCallQueue,
+	// e.g. Hadoop:service=ipc.8020,name=DecayRpcScheduler or
+	// Hadoop:service=NameNode,name=DecayRpcSchedulerMetrics2.ipc.8020.
+	ipcPort = regexp.MustCompile(`ipc\.(\d+)`)
+	// priorityAttribute matches the per-priority attributes of
+	// DecayRpcSchedulerMetrics2, e.g. Priority.0.AvgResponseTime.
+	priorityAttribute = regexp.MustCompile(`^Priority\.(\d+)\.(CompletedCallVolume|AvgResponseTime)$`)
+)
+
+// callQueue collects the per-user open connections of the RPC servers and,
+// with the FairCallQueue, the per-user and per-priority metrics of the
+// DecayRpcScheduler, so the users saturating the RPC queue stand out.
+type callQueue struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (callQueue) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	switch name := bean.Name(); {
+	case strings.HasPrefix(bean.ModelerType(), "RpcActivityForPort"):
+		collectOpenConnections(e, bean)
+	case strings.HasSuffix(name, ",name=DecayRpcScheduler"):
+		collectDecayRpcScheduler(e, bean)
+	case strings.Contains(name, ",name=DecayRpcSchedulerMetrics2."):
+		collectDecayRpcSchedulerMetrics(e, bean)
+	case strings.HasSuffix(name, ",name=FairCallQueue"):
+		collectFairCallQueue(e, bean)
+	}
+}
+
+// collectOpenConnections sends the tag.NumOpenConnectionsPerUser of an
+// RpcActivityForPort<port> bean: {"hdfs":12,"alice":3}.
+func collectOpenConnections(e *lib.Emitter, bean *lib.Bean) {
+	// tag.NumOpenConnectionsPerUser is missing before Hadoop 2.8.
+	if !bean.Has("tag.NumOpenConnectionsPerUser") {
+		return
+	}
+	port, ok := bean.String("tag.port")
+	if !ok {
+		return
+	}
+	users, ok := bean.JSON("tag.NumOpenConnectionsPerUser")
+	if !ok {
+		return
+	}
+	for _, user := range topUsers(users) {
+		e.BeanGauge(rpcUserConnectionsDesc, users, user, port, user)
+	}
+}
+
+// collectDecayRpcScheduler sends the metrics of the DecayRpcScheduler bean,
+// whose CallVolumeSummary and SchedulingDecisionSummary hold the decayed
+// call volume and the priority level of every user: {"alice":1234.5}.
+func collectDecayRpcScheduler(e *lib.Emitter, bean *lib.Bean) {
+	port, ok := beanIPCPort(bean)
+	if !ok {
+		return
+	}
+	e.BeanGauge(rpcUniqueCallersDesc, bean, "UniqueIdentityCount", port)
+	e.BeanGauge(rpcTotalCallVolumeDesc, bean, "TotalCallVolume", port)
+
+	volumes, ok := bean.JSON("CallVolumeSummary")
+	if !ok {
+		return
+	}
+	priorities, _ := bean.JSON("SchedulingDecisionSummary")
+	for _, user := range topUsers(volumes) {
+		e.BeanGauge(rpcUserCallVolumeDesc, volumes, user, port, user)
+		if priorities != nil && priorities.Has(user) {
+			e.BeanGauge(rpcUserPriorityDesc, priorities, user, port, user)
+		}
+	}
+}
+
+// collectDecayRpcSchedulerMetrics sends the per-priority attributes of the
+// DecayRpcSchedulerMetrics2 bean. Its per-user Caller(<user>).Volume
+// attributes repeat those of the DecayRpcScheduler bean for the top users.
+func collectDecayRpcSchedulerMetrics(e *lib.Emitter, bean *lib.Bean) {
+	port, ok := beanIPCPort(bean)
+	if !ok {
+		return
+	}
+	for _, attr := range bean.Keys() {
+		m := priorityAttribute.FindStringSubmatch(attr)
+		if m == nil {
+			continue
+		}
+		switch m[2] {
+		case "CompletedCallVolume":
+			e.BeanGauge(rpcPriorityCallsDesc, bean, attr, port, m[1])
+		case "AvgResponseTime":
+			if v, ok := bean.Float(attr); ok {
+				e.Gauge(rpcPriorityResponseDesc, v/1000, port, m[1])
+			}
+		}
+	}
+}
+
+// collectFairCallQueue sends the QueueSizes and OverflowedCalls arrays of the
+// FairCallQueue bean, indexed by priority level.
+func collectFairCallQueue(e *lib.Emitter, bean *lib.Bean) {
+	port, ok := beanIPCPort(bean)
+	if !ok {
+		return
+	}
+	if sizes, ok := bean.Floats("QueueSizes"); ok {
+		for priority, size := range sizes {
+			e.Gauge(rpcCallQueueLengthDesc, size, port, strconv.Itoa(priority))
+		}
+	}
+	if overflowed, ok := bean.Floats("OverflowedCalls"); ok {
+		for priority, calls := range overflowed {
+			e.Counter(rpcCallQueueOverflowDesc, calls, port, strconv.Itoa(priority))
+		}
+	}
+}
+
+func beanIPCPort(bean *lib.Bean) (string, bool) {
+	m := ipcPort.FindStringSubmatch(bean.Name())
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// topUsers returns the users of a JSON object of numbers by user, such as
+// {"alice":3,"bob":5}, with the highest numbers first, at most rpcUserLimit.
+func topUsers(users *lib.Bean) []string {
+	if rpcUserLimit == 0 {
+		return nil
+	}
+
+	type user struct {
+		name  string
+		value float64
+	}
+	var sorted []user
+	for _, name := range users.Keys() {
+		if v, ok := users.Float(name); ok {
+			sorted = append(sorted, user{name, v})
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].value > sorted[j].value })
+	if rpcUserLimit > 0 && len(sorted) > rpcUserLimit {
+		sorted = sorted[:rpcUserLimit]
+	}
+
+	names := make([]string, len(sorted))
+	for i, u := range sorted {
+		names[i] = u.name
+	}
+	return names
+}
@@ -0,0 +1,221 @@
+package namenode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallQueue(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jmx  string
+		want map[string]float64
+	}{
+		{
+			name: "open connections",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=RpcActivityForPort8020",
+				"modelerType": "RpcActivityForPort8020",
+				"tag.port": "8020",
+				"tag.Context": "rpc",
+				"tag.NumOpenConnectionsPerUser": "{\"hdfs\":12,\"alice\":3,\"bob\":5}",
+				"ReceivedBytes": 1505609759776,
+				"NumOpenConnections": 20
+			},{
+				"name": "Hadoop:service=NameNode,name=RpcActivityForPort8060",
+				"modelerType": "RpcActivityForPort8060",
+				"tag.port": "8060",
+				"tag.NumOpenConnectionsPerUser": "{}",
+				"NumOpenConnections": 0
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_user_open_connections{port="8020",user="hdfs"}`:  12,
+				`hdfs_namenode_rpc_user_open_connections{port="8020",user="bob"}`:   5,
+				`hdfs_namenode_rpc_user_open_connections{port="8020",user="alice"}`: 3,
+			},
+		},
+		{
+			name: "open connections before Hadoop 2.8",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=RpcActivityForPort8020",
+				"modelerType": "RpcActivityForPort8020",
+				"tag.port": "8020",
+				"NumOpenConnections": 20
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "malformed open connections",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=RpcActivityForPort8020",
+				"modelerType": "RpcActivityForPort8020",
+				"tag.port": "8020",
+				"tag.NumOpenConnectionsPerUser": "{\"hdfs\":"
+			},{
+				"name": "Hadoop:service=NameNode,name=RpcActivityForPort8060",
+				"modelerType": "RpcActivityForPort8060",
+				"tag.NumOpenConnectionsPerUser": "{\"hdfs\":1}"
+			},{
+				"name": "Hadoop:service=NameNode,name=RpcActivityForPort8021",
+				"modelerType": "RpcActivityForPort8021",
+				"tag.port": "8021",
+				"tag.NumOpenConnectionsPerUser": "{\"hdfs\":\"many\",\"alice\":2}"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_user_open_connections{port="8021",user="alice"}`: 2,
+			},
+		},
+		{
+			name: "DecayRpcScheduler",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=ipc.8020,name=DecayRpcScheduler",
+				"modelerType": "org.apache.hadoop.ipc.DecayRpcScheduler",
+				"UniqueIdentityCount": 3,
+				"TotalCallVolume": 1500,
+				"CallVolumeSummary": "{\"alice\":1000,\"bob\":400,\"hdfs\":100}",
+				"SchedulingDecisionSummary": "{\"alice\":3,\"bob\":1}",
+				"AverageResponseTimes": [0.5, 1.0, 0.0, 0.0],
+				"ResponseTimeCountInLastWindow": [10, 2, 0, 0]
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_scheduler_unique_callers{port="8020"}`:      3,
+				`hdfs_namenode_rpc_scheduler_call_volume{port="8020"}`:         1500,
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="alice"}`: 1000,
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="bob"}`:   400,
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="hdfs"}`:  100,
+				`hdfs_namenode_rpc_user_priority{port="8020",user="alice"}`:    3,
+				`hdfs_namenode_rpc_user_priority{port="8020",user="bob"}`:      1,
+			},
+		},
+		{
+			name: "DecayRpcScheduler without summaries",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=ipc.8020,name=DecayRpcScheduler",
+				"UniqueIdentityCount": 0,
+				"TotalCallVolume": 0,
+				"CallVolumeSummary": "",
+				"SchedulingDecisionSummary": "No active scheduler"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_scheduler_unique_callers{port="8020"}`: 0,
+				`hdfs_namenode_rpc_scheduler_call_volume{port="8020"}`:    0,
+			},
+		},
+		{
+			name: "DecayRpcScheduler with summaries as objects",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=ipc.8020,name=DecayRpcScheduler",
+				"UniqueIdentityCount": 1,
+				"CallVolumeSummary": {"alice": 1000},
+				"SchedulingDecisionSummary": {"alice": 3}
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_scheduler_unique_callers{port="8020"}`: 1,
+			},
+		},
+		{
+			name: "DecayRpcSchedulerMetrics2",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=DecayRpcSchedulerMetrics2.ipc.8020",
+				"modelerType": "DecayRpcSchedulerMetrics2.ipc.8020",
+				"tag.Context": "ipc.8020",
+				"Priority.0.CompletedCallVolume": 10,
+				"Priority.0.AvgResponseTime": 0.5,
+				"Priority.1.CompletedCallVolume": 2,
+				"Priority.1.AvgResponseTime": 1.0,
+				"Priority.2.AvgResponseTime": "n/a",
+				"Caller(alice).Volume": 1000,
+				"Caller(alice).Priority": 3,
+				"UniqueCallers": 3,
+				"CallVolume": 1500
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_priority_completed_call_volume{port="8020",priority="0"}`:     10,
+				`hdfs_namenode_rpc_priority_completed_call_volume{port="8020",priority="1"}`:     2,
+				`hdfs_namenode_rpc_priority_avg_response_time_seconds{port="8020",priority="0"}`: 0.5 / 1000,
+				`hdfs_namenode_rpc_priority_avg_response_time_seconds{port="8020",priority="1"}`: 1.0 / 1000,
+			},
+		},
+		{
+			name: "FairCallQueue",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=ipc.8020,name=FairCallQueue",
+				"modelerType": "org.apache.hadoop.ipc.FairCallQueue",
+				"QueueSizes": [0, 1, 5, 20],
+				"OverflowedCalls": [0, 0, 2, 7],
+				"Revision": 1
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_call_queue_length{port="8020",priority="0"}`:                 0,
+				`hdfs_namenode_rpc_call_queue_length{port="8020",priority="1"}`:                 1,
+				`hdfs_namenode_rpc_call_queue_length{port="8020",priority="2"}`:                 5,
+				`hdfs_namenode_rpc_call_queue_length{port="8020",priority="3"}`:                 20,
+				`hdfs_namenode_rpc_call_queue_overflowed_calls_total{port="8020",priority="0"}`: 0,
+				`hdfs_namenode_rpc_call_queue_overflowed_calls_total{port="8020",priority="1"}`: 0,
+				`hdfs_namenode_rpc_call_queue_overflowed_calls_total{port="8020",priority="2"}`: 2,
+				`hdfs_namenode_rpc_call_queue_overflowed_calls_total{port="8020",priority="3"}`: 7,
+			},
+		},
+		{
+			name: "FairCallQueue of another shape",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=ipc.8020,name=FairCallQueue",
+				"QueueSizes": "0,1,5,20",
+				"OverflowedCalls": [0, "many", 2]
+			},{
+				"name": "Hadoop:service=NameNode,name=FairCallQueue",
+				"QueueSizes": [3]
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "no call queue",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=FSNamesystem",
+				"modelerType": "FSNamesystem",
+				"CallVolumeSummary": "{\"alice\":1000}",
+				"QueueSizes": [0]
+			}]}`,
+			want: map[string]float64{},
+		},
+	} {
+		if got := collect(t, callQueue{}, tt.jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRPCUserLimit(t *testing.T) {
+	defer func(limit int) { rpcUserLimit = limit }(rpcUserLimit)
+	jmx := `{"beans":[{
+		"name": "Hadoop:service=ipc.8020,name=DecayRpcScheduler",
+		"CallVolumeSummary": "{\"alice\":1000,\"bob\":400,\"hdfs\":100}"
+	}]}`
+
+	for _, tt := range []struct {
+		limit int
+		want  map[string]float64
+	}{
+		{limit: 0, want: map[string]float64{}},
+		{
+			limit: 2,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="alice"}`: 1000,
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="bob"}`:   400,
+			},
+		},
+		{
+			limit: -1,
+			want: map[string]float64{
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="alice"}`: 1000,
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="bob"}`:   400,
+				`hdfs_namenode_rpc_user_call_volume{port="8020",user="hdfs"}`:  100,
+			},
+		},
+	} {
+		rpcUserLimit = tt.limit
+		if got := collect(t, callQueue{}, jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limit %d: got %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package namenode
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+var (
+	sinceCheckpointDesc = lib.NewMetricDesc(namespace, "fsname_system", "seconds_since_last_checkpoint", "Seconds since the last checkpoint")
+	journalSyncTimeDesc = lib.NewMetricDesc(namespace, "fsname_system", "journal_sync_time_seconds_total", "Total time spent syncing the edit log to the journal, numbered in the order of the NameJournalStatus of NameNodeInfo", "journal")
+)
+
+// checkpoint collects the edit log health of the FSNamesystem bean that the
+// rules cannot express.
+type checkpoint struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (checkpoint) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	if bean.Name() != "Hadoop:service=NameNode,name=FSNamesystem" {
+		return
+	}
+
+	// LastCheckpointTime is 0 until the first checkpoint is known.
+	if last, ok := bean.Float("LastCheckpointTime"); ok && last > 0 {
+		e.Gauge(sinceCheckpointDesc, time.Since(time.UnixMilli(int64(last))).Seconds())
+	}
+
+	// tag.TotalSyncTimes holds the total sync time in milliseconds of every
+	// journal of the edit log, e.g. "23 6 ". It is empty on a standby
+	// NameNode, which writes no edits.
+	if !bean.Has("tag.TotalSyncTimes") {
+		return
+	}
+	syncTimes, ok := bean.String("tag.TotalSyncTimes")
+	if !ok {
+		return
+	}
+	for i, field := range strings.Fields(syncTimes) {
+		if v, err := strconv.ParseFloat(field, 64); err == nil {
+			e.Counter(journalSyncTimeDesc, v/1000, strconv.Itoa(i))
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package namenode
+
+import (
+	"sort"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+// dataNodeLimit is the maximum number of DataNodes with per-DataNode metrics.
+var dataNodeLimit = 1000
+
+var (
+	dataNodesDesc               = lib.NewMetricDesc(namespace, "", "datanodes", "Number of DataNodes known to the NameNode by state", "state")
+	dataNodeInfoDesc            = lib.NewMetricDesc(namespace, "datanode", "info", "DataNode known to the NameNode, with its state, admin state and version", "datanode", "state", "admin_state", "version")
+	dataNodeLastContact         = lib.NewMetricDesc(namespace, "datanode", "last_contact_seconds", "Seconds since the last heartbeat of the DataNode", "datanode", "state")
+	dataNodeCapacity            = lib.NewMetricDesc(namespace, "datanode", "capacity_bytes", "Configured capacity of the DataNode", "datanode")
+	dataNodeUsed                = lib.NewMetricDesc(namespace, "datanode", "used_bytes", "Space used by HDFS on the DataNode", "datanode")
+	dataNodeRemaining           = lib.NewMetricDesc(namespace, "datanode", "remaining_bytes", "Space remaining for HDFS on the DataNode", "datanode")
+	dataNodeBlocks              = lib.NewMetricDesc(namespace, "datanode", "blocks", "Number of blocks on the DataNode", "datanode")
+	dataNodeVolumeFailures      = lib.NewMetricDesc(namespace, "datanode", "volume_failures", "Number of failed volumes of the DataNode", "datanode")
+	dataNodeUnderReplicated     = lib.NewMetricDesc(namespace, "datanode", "under_replicated_blocks", "Number of under-replicated blocks of a DataNode being decommissioned or entering maintenance", "datanode", "operation")
+	dataNodeOnlyReplicas        = lib.NewMetricDesc(namespace, "datanode", "only_replica_blocks", "Number of blocks whose only replicas are on a DataNode being decommissioned or entering maintenance", "datanode", "operation")
+	dataNodeUnderReplicatedOpen = lib.NewMetricDesc(namespace, "datanode", "under_replicated_open_file_blocks", "Number of under-replicated blocks of open files of a DataNode being decommissioned or entering maintenance", "datanode", "operation")
+)
+
+// dataNodes collects the DataNodes listed by the JSON attributes of the
+// NameNodeInfo bean, giving their capacity and liveness without an exporter
+// on every DataNode.
+type dataNodes struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (dataNodes) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	if bean.Name() != "Hadoop:service=NameNode,name=NameNodeInfo" {
+		return
+	}
+
+	live, liveOK := bean.JSON("LiveNodes")
+	dead, deadOK := bean.JSON("DeadNodes")
+	if liveOK {
+		e.Gauge(dataNodesDesc, float64(len(live.Keys())), "live")
+	}
+	if deadOK {
+		e.Gauge(dataNodesDesc, float64(len(dead.Keys())), "dead")
+	}
+	// Nodes being decommissioned or entering maintenance are live nodes
+	// too; these lists only add their block counts.
+	operations := map[string]*lib.Bean{}
+	for attr, operation := range map[string]string{"DecomNodes": "decommission", "EnteringMaintenanceNodes": "maintenance"} {
+		// EnteringMaintenanceNodes is missing before Hadoop 2.9.
+		if attr == "EnteringMaintenanceNodes" && !bean.Has(attr) {
+			continue
+		}
+		if nodes, ok := bean.JSON(attr); ok {
+			operations[operation] = nodes
+		}
+	}
+
+	if dataNodeLimit == 0 {
+		return
+	}
+	names := map[string]bool{}
+	for _, nodes := range []*lib.Bean{live, dead} {
+		if nodes == nil {
+			continue
+		}
+		for _, name := range nodes.Keys() {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	if dataNodeLimit > 0 && len(sorted) > dataNodeLimit {
+		sorted = sorted[:dataNodeLimit]
+	}
+
+	for _, name := range sorted {
+		if live != nil && live.Has(name) {
+			if node, ok := live.Object(name); ok {
+				collectLiveNode(e, name, node)
+			}
+		} else if node, ok := dead.Object(name); ok {
+			collectDeadNode(e, name, node)
+		}
+
+		for operation, nodes := range operations {
+			if !nodes.Has(name) {
+				continue
+			}
+			node, ok := nodes.Object(name)
+			if !ok {
+				continue
+			}
+			e.BeanGauge(dataNodeUnderReplicated, node, "underReplicatedBlocks", name, operation)
+			if attr := operation + "OnlyReplicas"; node.Has(attr) {
+				e.BeanGauge(dataNodeOnlyReplicas, node, attr, name, operation)
+			}
+			e.BeanGauge(dataNodeUnderReplicatedOpen, node, "underReplicateInOpenFiles", name, operation)
+		}
+	}
+}
+
+// collectLiveNode sends the metrics of an entry of LiveNodes:
+// {"dn01.example.com:9866":{"lastContact":1,"capacity":..., "used":..., "adminState":"In Service", "version":"3.3.6", ...}}
+func collectLiveNode(e *lib.Emitter, name string, node *lib.Bean) {
+	adminState, _ := node.String("adminState")
+	version, _ := node.String("version")
+	e.Gauge(dataNodeInfoDesc, 1, name, "live", adminState, version)
+
+	e.BeanGauge(dataNodeLastContact, node, "lastContact", name, "live")
+	e.BeanGauge(dataNodeCapacity, node, "capacity", name)
+	e.BeanGauge(dataNodeUsed, node, "used", name)
+	e.BeanGauge(dataNodeRemaining, node, "remaining", name)
+	e.BeanGauge(dataNodeBlocks, node, "numBlocks", name)
+	e.BeanGauge(dataNodeVolumeFailures, node, "volfails", name)
+}
+
+// collectDeadNode sends the metrics of an entry of DeadNodes:
+// {"dn02.example.com:9866":{"lastContact":86400,"decommissioned":false,"adminState":"In Service", ...}}
+func collectDeadNode(e *lib.Emitter, name string, node *lib.Bean) {
+	// adminState is missing before Hadoop 2.8.
+	adminState := ""
+	if node.Has("adminState") {
+		adminState, _ = node.String("adminState")
+	} else if decommissioned, ok := node.Float("decommissioned"); ok && decommissioned == 1 {
+		adminState = "Decommissioned"
+	}
+	e.Gauge(dataNodeInfoDesc, 1, name, "dead", adminState, "")
+
+	e.BeanGauge(dataNodeLastContact, node, "lastContact", name, "dead")
+}
@@ -0,0 +1,244 @@
+package namenode
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var descName = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// collect returns the series the collector sends for the beans of the /jmx
+// response, as name{label="value",...}, with their values.
+func collect(t *testing.T, c lib.BeanCollector, jmx string) map[string]float64 {
+	t.Helper()
+	beans, err := lib.ParseBeans([]byte(jmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return emitted(t, func(e *lib.Emitter) {
+		byName := map[string]*lib.Bean{}
+		for _, bean := range beans {
+			c.CollectBean(e, bean)
+			byName[bean.Name()] = bean
+		}
+		if d, ok := c.(lib.DerivedCollector); ok {
+			d.CollectDerived(e, byName)
+		}
+	})
+}
+
+// emitted returns the series send sends through an Emitter, as
+// name{label="value",...}, with their values.
+func emitted(t *testing.T, send func(e *lib.Emitter)) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 1000)
+	send(lib.NewEmitter(ch, nil, nil))
+	close(ch)
+
+	values := map[string]float64{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		name := descName.FindStringSubmatch(m.Desc().String())
+		if name == nil {
+			t.Fatalf("unexpected descriptor %s", m.Desc())
+		}
+		var labels []string
+		for _, l := range pb.GetLabel() {
+			labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+		}
+		sort.Strings(labels)
+		value := pb.GetGauge().GetValue()
+		if pb.Counter != nil {
+			value = pb.GetCounter().GetValue()
+		}
+		values[name[1]+"{"+strings.Join(labels, ",")+"}"] = value
+	}
+	return values
+}
+
+func TestDataNodes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jmx  string
+		want map[string]float64
+	}{
+		{
+			name: "Hadoop 3",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"modelerType": "org.apache.hadoop.hdfs.server.namenode.FSNamesystem",
+				"LiveNodes": "{\"dn01.example.com:9866\":{\"infoAddr\":\"10.0.0.1:9864\",\"infoSecureAddr\":\"10.0.0.1:0\",\"xferaddr\":\"10.0.0.1:9866\",\"location\":\"/default-rack\",\"uuid\":\"4f6a1c9e-0d2b-4a51-9d7e-2b1f6c3a8e10\",\"lastContact\":1,\"usedSpace\":100,\"adminState\":\"In Service\",\"nonDfsUsedSpace\":5,\"capacity\":1000,\"numBlocks\":42,\"version\":\"3.3.6\",\"used\":100,\"remaining\":895,\"blockScheduled\":0,\"blockPoolUsed\":100,\"blockPoolUsedPercent\":10.0,\"volfails\":0,\"lastBlockReport\":120},\"dn03.example.com:9866\":{\"infoAddr\":\"10.0.0.3:9864\",\"xferaddr\":\"10.0.0.3:9866\",\"lastContact\":2,\"adminState\":\"Decommission In Progress\",\"capacity\":2000,\"numBlocks\":7,\"version\":\"3.3.6\",\"used\":1,\"remaining\":1999,\"volfails\":1}}",
+				"DeadNodes": "{\"dn02.example.com:9866\":{\"lastContact\":86400,\"decommissioned\":false,\"adminState\":\"In Service\",\"xferaddr\":\"10.0.0.2:9866\",\"location\":\"/default-rack\",\"uuid\":\"9a1e5c2b-7f3d-4e8a-b6c1-0d2e4f6a8b9c\"}}",
+				"DecomNodes": "{\"dn03.example.com:9866\":{\"xferaddr\":\"10.0.0.3:9866\",\"underReplicatedBlocks\":3,\"decommissionOnlyReplicas\":1,\"underReplicateInOpenFiles\":0}}",
+				"EnteringMaintenanceNodes": "{}"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 2,
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="In Service",datanode="dn01.example.com:9866",state="live",version="3.3.6"}`:               1,
+				`hdfs_namenode_datanode_last_contact_seconds{datanode="dn01.example.com:9866",state="live"}`:                                        1,
+				`hdfs_namenode_datanode_capacity_bytes{datanode="dn01.example.com:9866"}`:                                                           1000,
+				`hdfs_namenode_datanode_used_bytes{datanode="dn01.example.com:9866"}`:                                                               100,
+				`hdfs_namenode_datanode_remaining_bytes{datanode="dn01.example.com:9866"}`:                                                          895,
+				`hdfs_namenode_datanode_blocks{datanode="dn01.example.com:9866"}`:                                                                   42,
+				`hdfs_namenode_datanode_volume_failures{datanode="dn01.example.com:9866"}`:                                                          0,
+				`hdfs_namenode_datanode_info{admin_state="In Service",datanode="dn02.example.com:9866",state="dead",version=""}`:                    1,
+				`hdfs_namenode_datanode_last_contact_seconds{datanode="dn02.example.com:9866",state="dead"}`:                                        86400,
+				`hdfs_namenode_datanode_info{admin_state="Decommission In Progress",datanode="dn03.example.com:9866",state="live",version="3.3.6"}`: 1,
+				`hdfs_namenode_datanode_last_contact_seconds{datanode="dn03.example.com:9866",state="live"}`:                                        2,
+				`hdfs_namenode_datanode_capacity_bytes{datanode="dn03.example.com:9866"}`:                                                           2000,
+				`hdfs_namenode_datanode_used_bytes{datanode="dn03.example.com:9866"}`:                                                               1,
+				`hdfs_namenode_datanode_remaining_bytes{datanode="dn03.example.com:9866"}`:                                                          1999,
+				`hdfs_namenode_datanode_blocks{datanode="dn03.example.com:9866"}`:                                                                   7,
+				`hdfs_namenode_datanode_volume_failures{datanode="dn03.example.com:9866"}`:                                                          1,
+				`hdfs_namenode_datanode_under_replicated_blocks{datanode="dn03.example.com:9866",operation="decommission"}`:                         3,
+				`hdfs_namenode_datanode_only_replica_blocks{datanode="dn03.example.com:9866",operation="decommission"}`:                             1,
+				`hdfs_namenode_datanode_under_replicated_open_file_blocks{datanode="dn03.example.com:9866",operation="decommission"}`:               0,
+			},
+		},
+		{
+			name: "Hadoop 2.7",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"LiveNodes": "{\"dn01.example.com:50010\":{\"infoAddr\":\"10.0.0.1:50075\",\"xferaddr\":\"10.0.0.1:50010\",\"lastContact\":0,\"usedSpace\":100,\"adminState\":\"In Service\",\"nonDfsUsedSpace\":5,\"capacity\":1000,\"numBlocks\":42,\"version\":\"2.7.3\",\"used\":100,\"remaining\":895,\"blockScheduled\":0,\"blockPoolUsed\":100,\"blockPoolUsedPercent\":10.0,\"volfails\":0}}",
+				"DeadNodes": "{\"dn02.example.com:50010\":{\"lastContact\":86400,\"decommissioned\":true,\"xferaddr\":\"10.0.0.2:50010\"}}",
+				"DecomNodes": "{}"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 1,
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="In Service",datanode="dn01.example.com:50010",state="live",version="2.7.3"}`: 1,
+				`hdfs_namenode_datanode_last_contact_seconds{datanode="dn01.example.com:50010",state="live"}`:                          0,
+				`hdfs_namenode_datanode_capacity_bytes{datanode="dn01.example.com:50010"}`:                                             1000,
+				`hdfs_namenode_datanode_used_bytes{datanode="dn01.example.com:50010"}`:                                                 100,
+				`hdfs_namenode_datanode_remaining_bytes{datanode="dn01.example.com:50010"}`:                                            895,
+				`hdfs_namenode_datanode_blocks{datanode="dn01.example.com:50010"}`:                                                     42,
+				`hdfs_namenode_datanode_volume_failures{datanode="dn01.example.com:50010"}`:                                            0,
+				`hdfs_namenode_datanode_info{admin_state="Decommissioned",datanode="dn02.example.com:50010",state="dead",version=""}`:  1,
+				`hdfs_namenode_datanode_last_contact_seconds{datanode="dn02.example.com:50010",state="dead"}`:                          86400,
+			},
+		},
+		{
+			name: "empty strings",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"LiveNodes": "",
+				"DeadNodes": "",
+				"DecomNodes": "",
+				"EnteringMaintenanceNodes": ""
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "empty objects",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"LiveNodes": "{}",
+				"DeadNodes": "{}",
+				"DecomNodes": "{}",
+				"EnteringMaintenanceNodes": "{}"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 0,
+				`hdfs_namenode_datanodes{state="dead"}`: 0,
+			},
+		},
+		{
+			name: "nodes lacking fields",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"LiveNodes": "{\"dn01.example.com:9866\":{},\"dn03.example.com:9866\":{\"capacity\":\"unknown\"},\"dn04.example.com:9866\":null}",
+				"DeadNodes": "{\"dn02.example.com:9866\":{}}",
+				"DecomNodes": "{\"dn03.example.com:9866\":{}}"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 3,
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="",datanode="dn01.example.com:9866",state="live",version=""}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="",datanode="dn02.example.com:9866",state="dead",version=""}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="",datanode="dn03.example.com:9866",state="live",version=""}`: 1,
+			},
+		},
+		{
+			name: "only dead nodes",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"LiveNodes": "not JSON",
+				"DeadNodes": "{\"dn02.example.com:9866\":{\"lastContact\":600,\"decommissioned\":false,\"adminState\":\"In Service\"}}"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="In Service",datanode="dn02.example.com:9866",state="dead",version=""}`: 1,
+				`hdfs_namenode_datanode_last_contact_seconds{datanode="dn02.example.com:9866",state="dead"}`:                     600,
+			},
+		},
+		{
+			name: "other bean",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=FSNamesystemState",
+				"LiveNodes": "{}"
+			}]}`,
+			want: map[string]float64{},
+		},
+	} {
+		if got := collect(t, dataNodes{}, tt.jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDataNodeLimit(t *testing.T) {
+	defer func(limit int) { dataNodeLimit = limit }(dataNodeLimit)
+	jmx := `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+		"LiveNodes": "{\"dn01.example.com:9866\":{\"version\":\"3.3.6\"}}",
+		"DeadNodes": "{\"dn02.example.com:9866\":{\"adminState\":\"In Service\"}}"
+	}]}`
+
+	for _, tt := range []struct {
+		limit int
+		want  map[string]float64
+	}{
+		{
+			limit: 0,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 1,
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+			},
+		},
+		{
+			limit: 1,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 1,
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="",datanode="dn01.example.com:9866",state="live",version="3.3.6"}`: 1,
+			},
+		},
+		{
+			limit: -1,
+			want: map[string]float64{
+				`hdfs_namenode_datanodes{state="live"}`: 1,
+				`hdfs_namenode_datanodes{state="dead"}`: 1,
+				`hdfs_namenode_datanode_info{admin_state="",datanode="dn01.example.com:9866",state="live",version="3.3.6"}`:      1,
+				`hdfs_namenode_datanode_info{admin_state="In Service",datanode="dn02.example.com:9866",state="dead",version=""}`: 1,
+			},
+		},
+	} {
+		dataNodeLimit = tt.limit
+		if got := collect(t, dataNodes{}, jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("limit %d: got %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package namenode
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// unknownState is the ha_state of a NameNode that could not be scraped
+	// or does not tell its state.
+	unknownState = "unknown"
+	// startingState is the ha_state of a NameNode still loading its
+	// namespace, before it tells its state.
+	startingState = "starting"
+)
+
+var (
+	activeNameNodesDesc = lib.NewMetricDesc(namespace, "nameservice", "active_namenodes", "Number of NameNodes of the nameservice in the active state")
+	nameNodesDesc       = lib.NewMetricDesc(namespace, "nameservice", "namenodes", "Number of NameNodes of the nameservice by HA state", "ha_state")
+	lastFailoverDesc    = lib.NewMetricDesc(namespace, "nameservice", "last_failover_timestamp_seconds", "Time of the latest HA transition of a NameNode of the nameservice")
+	sinceFailoverDesc   = lib.NewMetricDesc(namespace, "nameservice", "seconds_since_last_failover", "Seconds since the latest HA transition of a NameNode of the nameservice")
+)
+
+// HAExporter scrapes the NameNodes of a nameservice concurrently. Their
+// metrics are labelled with nn_id and ha_state, and cluster-level series
+// tell how many NameNodes are active and when the last failover happened.
+type HAExporter struct {
+	nameNodes []lib.Daemon
+	rules     *lib.Rules
+}
+
+// NewHAExporter returns the exporter of the NameNodes of a nameservice.
+func NewHAExporter(nameNodes []lib.Daemon, rules *lib.Rules) *HAExporter {
+	return &HAExporter{
+		nameNodes: nameNodes,
+		rules:     rules,
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (e *HAExporter) Describe(ch chan<- *prometheus.Desc) {
+	lib.DescribeScrape(ch)
+}
+
+// haStatus is what a NameNode tells about its place in the nameservice.
+type haStatus struct {
+	state string
+	// lastTransition is the LastHATransitionTime in milliseconds, 0 if unknown.
+	lastTransition float64
+}
+
+// CollectContext implements the lib.ContextCollector interface.
+func (e *HAExporter) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	statuses := make([]haStatus, len(e.nameNodes))
+
+	var wg sync.WaitGroup
+	for i, nn := range e.nameNodes {
+		wg.Add(1)
+		go func(i int, nn lib.Daemon) {
+			defer wg.Done()
+			statuses[i] = e.collectNameNode(ctx, ch, nn)
+		}(i, nn)
+	}
+	wg.Wait()
+
+	collectNameservice(lib.NewEmitter(ch, nil, nil), statuses, time.Now())
+}
+
+// collectNameservice sends the series of the nameservice as a whole, derived
+// from the statuses of its NameNodes at now.
+func collectNameservice(e *lib.Emitter, statuses []haStatus, now time.Time) {
+	var (
+		active         float64
+		counts         = map[string]float64{}
+		lastTransition float64
+	)
+	for _, s := range statuses {
+		counts[s.state]++
+		if s.state == "active" {
+			active++
+		}
+		if s.lastTransition > lastTransition {
+			lastTransition = s.lastTransition
+		}
+	}
+
+	e.Gauge(activeNameNodesDesc, active)
+	for state, n := range counts {
+		e.Gauge(nameNodesDesc, n, state)
+	}
+	if lastTransition > 0 {
+		last := time.UnixMilli(int64(lastTransition))
+		e.Gauge(lastFailoverDesc, lastTransition/1000)
+		e.Gauge(sinceFailoverDesc, now.Sub(last).Seconds())
+	}
+}
+
+func (e *HAExporter) collectNameNode(ctx context.Context, ch chan<- prometheus.Metric, nn lib.Daemon) haStatus {
+	scrape := lib.BeginScrape(ctx, nn.Client.Target())
+	status := haStatus{state: unknownState}
+
+	var beans []*lib.Bean
+	data, err := nn.Client.Fetch(ctx)
+	if err != nil {
+		scrape.FailFetch(err)
+	} else if beans, err = lib.ParseBeans(data); err != nil {
+		scrape.Fail(lib.StageParse, err)
+	} else {
+		status = nameNodeStatus(beans)
+	}
+
+	// The up and duration of the NameNode carry the same labels as its other
+	// metrics, so the NameNodes of the nameservice are told apart.
+	labelNames, labelValues := []string{"nn_id", "ha_state"}, []string{nn.ID, status.state}
+	emitter := lib.NewEmitter(ch, labelNames, labelValues)
+	defer scrape.Emit(emitter)
+
+	e.rules.CollectLabels(ch, scrape, beans, labelNames, labelValues)
+	lib.CollectBeans(emitter, scrape, beans, collectors)
+	return status
+}
+
+// nameNodeStatus reads the HA state from the NameNodeStatus bean, which
+// knows about Observer NameNodes, falling back to the tag.HAState of
+// FSNamesystem. A NameNode telling neither is starting as long as its
+// StartupProgress is not complete.
+func nameNodeStatus(beans []*lib.Bean) haStatus {
+	status := haStatus{state: unknownState}
+	starting := false
+	for _, bean := range beans {
+		switch bean.Name() {
+		case "Hadoop:service=NameNode,name=NameNodeStatus":
+			if state, ok := bean.String("State"); ok && state != "" {
+				status.state = strings.ToLower(state)
+			}
+			status.lastTransition, _ = bean.Float("LastHATransitionTime")
+		case "Hadoop:service=NameNode,name=FSNamesystem":
+			if state, ok := bean.String("tag.HAState"); ok && state != "" && status.state == unknownState {
+				status.state = strings.ToLower(state)
+			}
+		case "Hadoop:service=NameNode,name=StartupProgress":
+			complete, ok := bean.Float("PercentComplete")
+			starting = ok && complete < 1
+		}
+	}
+	if status.state == unknownState && starting {
+		status.state = startingState
+	}
+	return status
+}
@@ -0,0 +1,171 @@
+package namenode
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+func TestNameNodeStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jmx  string
+		want haStatus
+	}{
+		{
+			name: "active",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeStatus",
+				"modelerType": "org.apache.hadoop.hdfs.server.namenode.NameNode",
+				"SecurityEnabled": true,
+				"NNRole": "NameNode",
+				"HostAndPort": "nn01.example.com:8020",
+				"LastHATransitionTime": 1484149009998,
+				"State": "active"
+			},{
+				"name": "Hadoop:service=NameNode,name=FSNamesystem",
+				"tag.HAState": "active"
+			}]}`,
+			want: haStatus{state: "active", lastTransition: 1484149009998},
+		},
+		{
+			name: "observer",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=FSNamesystem",
+				"tag.HAState": "standby"
+			},{
+				"name": "Hadoop:service=NameNode,name=NameNodeStatus",
+				"LastHATransitionTime": 0,
+				"State": "observer"
+			}]}`,
+			want: haStatus{state: "observer"},
+		},
+		{
+			name: "state of FSNamesystem",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeStatus",
+				"State": ""
+			},{
+				"name": "Hadoop:service=NameNode,name=FSNamesystem",
+				"tag.HAState": "Standby"
+			}]}`,
+			want: haStatus{state: "standby"},
+		},
+		{
+			name: "starting",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=StartupProgress",
+				"PercentComplete": 0.4,
+				"ElapsedTime": 120000
+			},{
+				"name": "Hadoop:service=NameNode,name=FSNamesystem",
+				"tag.HAState": ""
+			}]}`,
+			want: haStatus{state: startingState},
+		},
+		{
+			name: "started without state",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=StartupProgress",
+				"PercentComplete": 1.0
+			}]}`,
+			want: haStatus{state: unknownState},
+		},
+		{
+			name: "state of another shape",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeStatus",
+				"LastHATransitionTime": "yesterday",
+				"State": {"value": "active"}
+			},{
+				"name": "Hadoop:service=NameNode,name=StartupProgress",
+				"PercentComplete": "n/a"
+			}]}`,
+			want: haStatus{state: unknownState},
+		},
+		{
+			name: "no beans",
+			jmx:  `{"beans":[]}`,
+			want: haStatus{state: unknownState},
+		},
+	} {
+		beans, err := lib.ParseBeans([]byte(tt.jmx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nameNodeStatus(beans); got != tt.want {
+			t.Errorf("%s: nameNodeStatus() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectNameservice(t *testing.T) {
+	const transition = 1484149009998
+	now := time.UnixMilli(transition).Add(time.Hour)
+
+	for _, tt := range []struct {
+		name     string
+		statuses []haStatus
+		want     map[string]float64
+	}{
+		{
+			name:     "active and standby",
+			statuses: []haStatus{{"active", transition}, {"standby", transition - 1000}},
+			want: map[string]float64{
+				`hdfs_namenode_nameservice_active_namenodes{}`:                1,
+				`hdfs_namenode_nameservice_namenodes{ha_state="active"}`:      1,
+				`hdfs_namenode_nameservice_namenodes{ha_state="standby"}`:     1,
+				`hdfs_namenode_nameservice_last_failover_timestamp_seconds{}`: transition / 1000.0,
+				`hdfs_namenode_nameservice_seconds_since_last_failover{}`:     3600,
+			},
+		},
+		{
+			name:     "no active NameNode",
+			statuses: []haStatus{{"standby", transition}, {"standby", 0}},
+			want: map[string]float64{
+				`hdfs_namenode_nameservice_active_namenodes{}`:                0,
+				`hdfs_namenode_nameservice_namenodes{ha_state="standby"}`:     2,
+				`hdfs_namenode_nameservice_last_failover_timestamp_seconds{}`: transition / 1000.0,
+				`hdfs_namenode_nameservice_seconds_since_last_failover{}`:     3600,
+			},
+		},
+		{
+			name:     "two active NameNodes",
+			statuses: []haStatus{{"active", transition - 1000}, {"active", transition}, {"observer", 0}},
+			want: map[string]float64{
+				`hdfs_namenode_nameservice_active_namenodes{}`:                2,
+				`hdfs_namenode_nameservice_namenodes{ha_state="active"}`:      2,
+				`hdfs_namenode_nameservice_namenodes{ha_state="observer"}`:    1,
+				`hdfs_namenode_nameservice_last_failover_timestamp_seconds{}`: transition / 1000.0,
+				`hdfs_namenode_nameservice_seconds_since_last_failover{}`:     3600,
+			},
+		},
+		{
+			name:     "unreachable NameNode",
+			statuses: []haStatus{{"active", transition}, {unknownState, 0}},
+			want: map[string]float64{
+				`hdfs_namenode_nameservice_active_namenodes{}`:                1,
+				`hdfs_namenode_nameservice_namenodes{ha_state="active"}`:      1,
+				`hdfs_namenode_nameservice_namenodes{ha_state="unknown"}`:     1,
+				`hdfs_namenode_nameservice_last_failover_timestamp_seconds{}`: transition / 1000.0,
+				`hdfs_namenode_nameservice_seconds_since_last_failover{}`:     3600,
+			},
+		},
+		{
+			name:     "no HA transition",
+			statuses: []haStatus{{unknownState, 0}, {startingState, 0}},
+			want: map[string]float64{
+				`hdfs_namenode_nameservice_active_namenodes{}`:             0,
+				`hdfs_namenode_nameservice_namenodes{ha_state="unknown"}`:  1,
+				`hdfs_namenode_nameservice_namenodes{ha_state="starting"}`: 1,
+			},
+		},
+	} {
+		got := emitted(t, func(e *lib.Emitter) { collectNameservice(e, tt.statuses, now) })
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package namenode
+
+import (
+	_ "embed"
+	"flag"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+const namespace = "hdfs_namenode"
+
+// defaultRules are the built-in rules mapping the beans to metrics.
+//
+//go:embed rules.yaml
+var defaultRules []byte
+
+// collectors send the metrics of the beans that the rules cannot express.
+var collectors = []lib.BeanCollector{lib.NewRPCCollector(namespace), lib.NewJVMCollector(namespace), checkpoint{}, safemode{}, dataNodes{}, rpcDetailed{}, callQueue{}, storageTypes{}, slowNodes{}}
+
+func registerFlags() {
+	flag.IntVar(&dataNodeLimit, "namenode.datanodes.limit", dataNodeLimit, "Maximum number of DataNodes, in the order of their names, with per-DataNode metrics parsed from NameNodeInfo. 0 disables them, -1 removes the limit.")
+	flag.StringVar(&rpcDetailedMethods, "namenode.rpc-detailed.methods", "", "Comma separated RPC methods, e.g. getBlockLocations,create, with per-method metrics from RpcDetailedActivity. Empty for all methods.")
+	flag.IntVar(&slowNodeLimit, "namenode.slow-nodes.limit", slowNodeLimit, "Maximum number of slow DataNodes and of slow disks, the slowest first, with metrics parsed from the SlowPeersReport and SlowDisksReport of NameNodeInfo. 0 disables them, -1 removes the limit.")
+	flag.IntVar(&rpcUserLimit, "namenode.rpc-users.limit", rpcUserLimit, "Maximum number of users, those with the most open connections or call volume first, with per-user RPC metrics. 0 disables them, -1 removes the limit.")
+}
+
+// Role is the NameNode role of the exporter.
+var Role = &lib.Role{
+	Name:          "namenode",
+	Title:         "NameNode",
+	Service:       "NameNode",
+	Namespace:     namespace,
+	ListenAddress: ":9070",
+	URL:           "http://nn01.example.com:50070",
+	Path:          "/jmx",
+	LegacyURLFlag: "namenode.jmx.url",
+	RegisterFlags: registerFlags,
+	Rules:         defaultRules,
+	NewExporter: func(client *lib.JmxClient, rules *lib.Rules) lib.ContextCollector {
+		return lib.NewJmxExporter(client, rules, collectors...)
+	},
+	NewGroupExporter: func(nameNodes []lib.Daemon, rules *lib.Rules) lib.ContextCollector {
+		return NewHAExporter(nameNodes, rules)
+	},
+}
@@ -0,0 +1,74 @@
+package namenode
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+// rpcDetailedMethods is the comma separated allowlist of the methods with
+// per-method RPC metrics, empty for all methods.
+var rpcDetailedMethods string
+
+var (
+	rpcDetailedCallsDesc   = lib.NewMetricDesc(namespace, "rpc_detailed", "calls_total", "Total number of RPC calls by method", "port", "method")
+	rpcDetailedAvgTimeDesc = lib.NewMetricDesc(namespace, "rpc_detailed", "avg_time_seconds", "Average processing time of the RPC calls by method in the last interval", "port", "method")
+)
+
+// rpcDetailed collects the RpcDetailedActivityForPort<port> beans, holding
+// <Method>NumOps and <Method>AvgTime for every call of the protocols served
+// on the port, e.g. GetBlockLocationsNumOps.
+type rpcDetailed struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (rpcDetailed) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	if !strings.HasPrefix(bean.ModelerType(), "RpcDetailedActivityForPort") {
+		return
+	}
+	port, ok := bean.String("tag.port")
+	if !ok {
+		return
+	}
+
+	for _, attr := range bean.Keys() {
+		switch {
+		case strings.HasSuffix(attr, "NumOps"):
+			if method := rpcMethod(strings.TrimSuffix(attr, "NumOps")); allowedRPCMethod(method) {
+				e.BeanCounter(rpcDetailedCallsDesc, bean, attr, port, method)
+			}
+		case strings.HasSuffix(attr, "AvgTime"):
+			if method := rpcMethod(strings.TrimSuffix(attr, "AvgTime")); allowedRPCMethod(method) {
+				if v, ok := bean.Float(attr); ok {
+					e.Gauge(rpcDetailedAvgTimeDesc, v/1000, port, method)
+				}
+			}
+		}
+	}
+}
+
+// rpcMethod returns the name of the protocol method of an attribute prefix,
+// e.g. getBlockLocations for GetBlockLocations.
+func rpcMethod(prefix string) string {
+	if prefix == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(prefix)
+	return string(unicode.ToLower(r)) + prefix[size:]
+}
+
+func allowedRPCMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	if rpcDetailedMethods == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(rpcDetailedMethods, ",") {
+		if strings.EqualFold(strings.TrimSpace(allowed), method) {
+			return true
+		}
+	}
+	return false
+}
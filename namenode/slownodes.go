@@ -0,0 +1,169 @@
+package namenode
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+)
+
+// slowNodeLimit is the maximum number of slow DataNodes and of slow disks
+// with per-node metrics.
+var slowNodeLimit = 10
+
+var (
+	slowPeersDesc       = lib.NewMetricDesc(namespace, "", "slow_peers", "Current number of DataNodes reported slow by their peers")
+	slowPeerReportDesc  = lib.NewMetricDesc(namespace, "slow_peer", "report", "DataNode reported slow by a peer writing to it", "slow_node", "reporting_node")
+	slowPeerLatencyDesc = lib.NewMetricDesc(namespace, "slow_peer", "reported_latency_seconds", "Average latency of sending packets to the slow DataNode reported by the peer", "slow_node", "reporting_node")
+	slowDisksDesc       = lib.NewMetricDesc(namespace, "", "slow_disks", "Current number of disks reported slow by their DataNode")
+	slowDiskLatencyDesc = lib.NewMetricDesc(namespace, "slow_disk", "latency_seconds", "Average latency of the operation on the slow disk", "datanode", "disk", "op")
+	slowDiskReportDesc  = lib.NewMetricDesc(namespace, "slow_disk", "report", "Disk reported slow by its DataNode", "datanode", "disk")
+)
+
+// slowNodes collects the SlowPeersReport and SlowDisksReport of the
+// NameNodeInfo bean, the outliers among the DataNodes and their disks that
+// drag the write pipelines. They are null unless
+// dfs.datanode.peer.stats.enabled and
+// dfs.datanode.fileio.profiling.sampling.percentage are set.
+type slowNodes struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (slowNodes) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	if bean.Name() != "Hadoop:service=NameNode,name=NameNodeInfo" {
+		return
+	}
+	if bean.Has("SlowPeersReport") {
+		if report, ok := bean.JSONObjects("SlowPeersReport"); ok {
+			collectSlowPeers(e, report)
+		}
+	}
+	if bean.Has("SlowDisksReport") {
+		if report, ok := bean.JSONObjects("SlowDisksReport"); ok {
+			collectSlowDisks(e, report)
+		}
+	}
+}
+
+// collectSlowPeers sends the SlowPeersReport, the DataNodes with the most
+// reporting peers first:
+// [{"SlowNode":"dn2:9866","ReportingNodes":["dn1:9866","dn3:9866"]}].
+// Since Hadoop 3.4 the report gives the latency seen by every peer instead:
+// [{"SlowNode":"dn2:9866","SlowPeerLatencyWithReportingNodes":[{"ReportingNode":"dn1:9866","ReportedLatency":12.5}]}].
+func collectSlowPeers(e *lib.Emitter, report []*lib.Bean) {
+	e.Gauge(slowPeersDesc, float64(len(report)))
+
+	type slowPeer struct {
+		node      string
+		reporters map[string]*lib.Bean // the latency report of the reporting node, if any
+	}
+	var peers []slowPeer
+	for _, entry := range report {
+		node, ok := entry.String("SlowNode")
+		if !ok {
+			continue
+		}
+		peer := slowPeer{node: node, reporters: map[string]*lib.Bean{}}
+		if entry.Has("SlowPeerLatencyWithReportingNodes") {
+			latencies, ok := entry.Objects("SlowPeerLatencyWithReportingNodes")
+			if !ok {
+				continue
+			}
+			for _, latency := range latencies {
+				if reporter, ok := latency.String("ReportingNode"); ok {
+					peer.reporters[reporter] = latency
+				}
+			}
+		} else if entry.Has("ReportingNodes") {
+			reporters, ok := entry.Strings("ReportingNodes")
+			if !ok {
+				continue
+			}
+			for _, reporter := range reporters {
+				peer.reporters[reporter] = nil
+			}
+		}
+		peers = append(peers, peer)
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		if len(peers[i].reporters) != len(peers[j].reporters) {
+			return len(peers[i].reporters) > len(peers[j].reporters)
+		}
+		return peers[i].node < peers[j].node
+	})
+	peers = peers[:limitSlowNodes(len(peers))]
+
+	for _, peer := range peers {
+		for reporter, latency := range peer.reporters {
+			e.Gauge(slowPeerReportDesc, 1, peer.node, reporter)
+			if latency == nil || !latency.Has("ReportedLatency") {
+				continue
+			}
+			if v, ok := latency.Float("ReportedLatency"); ok {
+				e.Gauge(slowPeerLatencyDesc, v/1000, peer.node, reporter)
+			}
+		}
+	}
+}
+
+// collectSlowDisks sends the SlowDisksReport, the disks with the highest
+// latency first:
+// [{"SlowDiskID":"dn1:9866:/data/1","Latencies":{"READ":25.3,"WRITE":4.1}}].
+func collectSlowDisks(e *lib.Emitter, report []*lib.Bean) {
+	e.Gauge(slowDisksDesc, float64(len(report)))
+
+	type slowDisk struct {
+		dataNode, disk string
+		latencies      *lib.Bean
+		max            float64
+	}
+	var disks []slowDisk
+	for _, entry := range report {
+		id, ok := entry.String("SlowDiskID")
+		if !ok {
+			continue
+		}
+		latencies, ok := entry.Object("Latencies")
+		if !ok {
+			continue
+		}
+		disk := slowDisk{latencies: latencies}
+		disk.dataNode, disk.disk = splitDiskID(id)
+		for _, op := range latencies.Keys() {
+			if v, ok := latencies.Float(op); ok && v > disk.max {
+				disk.max = v
+			}
+		}
+		disks = append(disks, disk)
+	}
+	sort.SliceStable(disks, func(i, j int) bool { return disks[i].max > disks[j].max })
+	disks = disks[:limitSlowNodes(len(disks))]
+
+	for _, disk := range disks {
+		e.Gauge(slowDiskReportDesc, 1, disk.dataNode, disk.disk)
+		for _, op := range disk.latencies.Keys() {
+			if v, ok := disk.latencies.Float(op); ok {
+				e.Gauge(slowDiskLatencyDesc, v/1000, disk.dataNode, disk.disk, op)
+			}
+		}
+	}
+}
+
+// splitDiskID splits the ID of a slow disk, e.g. "dn1:9866:/data/1", into
+// the DataNode and the disk.
+func splitDiskID(id string) (dataNode, disk string) {
+	if i := strings.Index(id, ":/"); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return "", id
+}
+
+// limitSlowNodes returns how many of n slow nodes or disks to send.
+func limitSlowNodes(n int) int {
+	if slowNodeLimit >= 0 && n > slowNodeLimit {
+		return slowNodeLimit
+	}
+	return n
+}
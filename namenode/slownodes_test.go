@@ -0,0 +1,148 @@
+package namenode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSlowNodes(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		jmx  string
+		want map[string]float64
+	}{
+		{
+			name: "Hadoop 3.3",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"SlowPeersReport": "[{\"SlowNode\":\"dn2.example.com:9866\",\"ReportingNodes\":[\"dn1.example.com:9866\",\"dn3.example.com:9866\"]},{\"SlowNode\":\"dn4.example.com:9866\",\"ReportingNodes\":[\"dn1.example.com:9866\"]}]",
+				"SlowDisksReport": "[{\"SlowDiskID\":\"dn1.example.com:9866:/data/1\",\"Latencies\":{\"READ\":25.5,\"WRITE\":4.25}},{\"SlowDiskID\":\"dn3.example.com:9866:/data/2\",\"Latencies\":{\"METADATA\":100}}]"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_slow_peers{}`: 2,
+				`hdfs_namenode_slow_peer_report{reporting_node="dn1.example.com:9866",slow_node="dn2.example.com:9866"}`: 1,
+				`hdfs_namenode_slow_peer_report{reporting_node="dn3.example.com:9866",slow_node="dn2.example.com:9866"}`: 1,
+				`hdfs_namenode_slow_peer_report{reporting_node="dn1.example.com:9866",slow_node="dn4.example.com:9866"}`: 1,
+				`hdfs_namenode_slow_disks{}`: 2,
+				`hdfs_namenode_slow_disk_report{datanode="dn1.example.com:9866",disk="/data/1"}`:                        1,
+				`hdfs_namenode_slow_disk_latency_seconds{datanode="dn1.example.com:9866",disk="/data/1",op="READ"}`:     25.5 / 1000,
+				`hdfs_namenode_slow_disk_latency_seconds{datanode="dn1.example.com:9866",disk="/data/1",op="WRITE"}`:    4.25 / 1000,
+				`hdfs_namenode_slow_disk_report{datanode="dn3.example.com:9866",disk="/data/2"}`:                        1,
+				`hdfs_namenode_slow_disk_latency_seconds{datanode="dn3.example.com:9866",disk="/data/2",op="METADATA"}`: 100.0 / 1000,
+			},
+		},
+		{
+			name: "Hadoop 3.4",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"SlowPeersReport": "[{\"SlowNode\":\"dn2.example.com:9866\",\"SlowPeerLatencyWithReportingNodes\":[{\"ReportingNode\":\"dn1.example.com:9866\",\"ReportedLatency\":12.5},{\"ReportingNode\":\"dn3.example.com:9866\"}]}]"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_slow_peers{}`: 1,
+				`hdfs_namenode_slow_peer_report{reporting_node="dn1.example.com:9866",slow_node="dn2.example.com:9866"}`:                   1,
+				`hdfs_namenode_slow_peer_reported_latency_seconds{reporting_node="dn1.example.com:9866",slow_node="dn2.example.com:9866"}`: 12.5 / 1000,
+				`hdfs_namenode_slow_peer_report{reporting_node="dn3.example.com:9866",slow_node="dn2.example.com:9866"}`:                   1,
+			},
+		},
+		{
+			name: "before Hadoop 2.9",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"LiveNodes": "{}"
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "disabled",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"SlowPeersReport": null,
+				"SlowDisksReport": null
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "empty reports",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"SlowPeersReport": "[]",
+				"SlowDisksReport": "[]"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_slow_peers{}`: 0,
+				`hdfs_namenode_slow_disks{}`: 0,
+			},
+		},
+		{
+			name: "malformed reports",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"SlowPeersReport": "[{\"SlowNode\":",
+				"SlowDisksReport": "{\"SlowDiskID\":\"dn1.example.com:9866:/data/1\"}"
+			}]}`,
+			want: map[string]float64{},
+		},
+		{
+			name: "entries of another shape",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+				"SlowPeersReport": "[{},{\"SlowNode\":\"dn2.example.com:9866\",\"ReportingNodes\":\"dn1.example.com:9866\"},{\"SlowNode\":\"dn4.example.com:9866\",\"SlowPeerLatencyWithReportingNodes\":[{\"ReportingNode\":\"dn1.example.com:9866\",\"ReportedLatency\":\"high\"}]},{\"SlowNode\":\"dn5.example.com:9866\"}]",
+				"SlowDisksReport": "[{\"SlowDiskID\":\"dn1.example.com:9866:/data/1\"},{\"Latencies\":{\"READ\":25.5}},{\"SlowDiskID\":\"/data/3\",\"Latencies\":{\"READ\":\"slow\"}}]"
+			}]}`,
+			want: map[string]float64{
+				`hdfs_namenode_slow_peers{}`: 4,
+				`hdfs_namenode_slow_peer_report{reporting_node="dn1.example.com:9866",slow_node="dn4.example.com:9866"}`: 1,
+				`hdfs_namenode_slow_disks{}`:                                 3,
+				`hdfs_namenode_slow_disk_report{datanode="",disk="/data/3"}`: 1,
+			},
+		},
+		{
+			name: "other bean",
+			jmx: `{"beans":[{
+				"name": "Hadoop:service=NameNode,name=FSNamesystem",
+				"SlowPeersReport": "[]"
+			}]}`,
+			want: map[string]float64{},
+		},
+	} {
+		if got := collect(t, slowNodes{}, tt.jmx); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSlowNodeLimit(t *testing.T) {
+	defer func(limit int) { slowNodeLimit = limit }(slowNodeLimit)
+	slowNodeLimit = 1
+	jmx := `{"beans":[{
+		"name": "Hadoop:service=NameNode,name=NameNodeInfo",
+		"SlowPeersReport": "[{\"SlowNode\":\"dn4.example.com:9866\",\"ReportingNodes\":[\"dn1.example.com:9866\"]},{\"SlowNode\":\"dn2.example.com:9866\",\"ReportingNodes\":[\"dn1.example.com:9866\",\"dn3.example.com:9866\"]}]",
+		"SlowDisksReport": "[{\"SlowDiskID\":\"dn1.example.com:9866:/data/1\",\"Latencies\":{\"READ\":25.5}},{\"SlowDiskID\":\"dn3.example.com:9866:/data/2\",\"Latencies\":{\"WRITE\":100}}]"
+	}]}`
+	want := map[string]float64{
+		`hdfs_namenode_slow_peers{}`: 2,
+		`hdfs_namenode_slow_peer_report{reporting_node="dn1.example.com:9866",slow_node="dn2.example.com:9866"}`: 1,
+		`hdfs_namenode_slow_peer_report{reporting_node="dn3.example.com:9866",slow_node="dn2.example.com:9866"}`: 1,
+		`hdfs_namenode_slow_disks{}`: 2,
+		`hdfs_namenode_slow_disk_report{datanode="dn3.example.com:9866",disk="/data/2"}`:                     1,
+		`hdfs_namenode_slow_disk_latency_seconds{datanode="dn3.example.com:9866",disk="/data/2",op="WRITE"}`: 100.0 / 1000,
+	}
+	if got := collect(t, slowNodes{}, jmx); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSplitDiskID(t *testing.T) {
+	for _, tt := range []struct {
+		id, dataNode, disk string
+	}{
+		{"dn1.example.com:9866:/data/1", "dn1.example.com:9866", "/data/1"},
+		{"dn1.example.com:/data/1", "dn1.example.com", "/data/1"},
+		{"dn1.example.com:9866:disk1", "dn1.example.com:9866", "disk1"},
+		{"/data/1", "", "/data/1"},
+	} {
+		if dataNode, disk := splitDiskID(tt.id); dataNode != tt.dataNode || disk != tt.disk {
+			t.Errorf("splitDiskID(%q) = %q, %q, want %q, %q", tt.id, dataNode, disk, tt.dataNode, tt.disk)
+		}
+	}
+}
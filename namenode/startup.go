@@ -0,0 +1,39 @@
+package namenode
+
+import "github.com/meoww-bot/hadoop_exporter/lib"
+
+var safemodeDesc = lib.NewMetricDesc(namespace, "", "safemode", "Whether the NameNode is in safe mode")
+
+// safemode tells whether the NameNode is in safe mode from the FSState of
+// FSNamesystemState or, failing that, from the Safemode of NameNodeInfo.
+// Neither bean is there while the NameNode loads its namespace at startup,
+// when the StartupProgress bean tells how far it got.
+type safemode struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (safemode) CollectBean(e *lib.Emitter, bean *lib.Bean) {}
+
+// CollectDerived implements the lib.DerivedCollector interface.
+func (safemode) CollectDerived(e *lib.Emitter, beans map[string]*lib.Bean) {
+	if bean, ok := beans["Hadoop:service=NameNode,name=FSNamesystemState"]; ok && bean.Has("FSState") {
+		// FSState is "safeMode" or "Operational".
+		if state, ok := bean.String("FSState"); ok {
+			e.Gauge(safemodeDesc, boolValue(state == "safeMode"))
+			return
+		}
+	}
+	if bean, ok := beans["Hadoop:service=NameNode,name=NameNodeInfo"]; ok && bean.Has("Safemode") {
+		// Safemode is empty or tells why the NameNode is in safe mode,
+		// e.g. "Safe mode is ON. The reported blocks 12 needs ...".
+		if message, ok := bean.String("Safemode"); ok {
+			e.Gauge(safemodeDesc, boolValue(message != ""))
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
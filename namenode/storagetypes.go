@@ -0,0 +1,54 @@
+package namenode
+
+import "github.com/meoww-bot/hadoop_exporter/lib"
+
+var (
+	storageTypeCapacityDesc = lib.NewMetricDesc(namespace, "block_stats", "capacity_bytes", "Current capacity of the DataNode storages of the storage type in each mode in bytes", "storage_type", "mode")
+	storageTypeNodesDesc    = lib.NewMetricDesc(namespace, "block_stats", "nodes_in_service", "Current number of in service DataNodes with storages of the storage type", "storage_type")
+)
+
+// storageTypeModes names the mode label of the capacities of StorageTypeStats
+// like the Capacity* attributes of FSNamesystem.
+var storageTypeModes = map[string]string{
+	"capacityTotal":      "Total",
+	"capacityUsed":       "Used",
+	"capacityRemaining":  "Remaining",
+	"capacityNonDfsUsed": "UsedNonDFS",
+	"blockPoolUsed":      "BlockPoolUsed",
+}
+
+// storageTypes collects the StorageTypeStats of the BlockStats bean, the
+// capacity of the DataNodes broken down by storage type:
+// [{"key":"DISK","value":{"capacityTotal":...,"nodesInService":3,...}}, ...].
+type storageTypes struct{}
+
+// CollectBean implements the lib.BeanCollector interface.
+func (storageTypes) CollectBean(e *lib.Emitter, bean *lib.Bean) {
+	if bean.Name() != "Hadoop:service=NameNode,name=BlockStats" {
+		return
+	}
+	// StorageTypeStats is missing before Hadoop 2.8.
+	if !bean.Has("StorageTypeStats") {
+		return
+	}
+	entries, ok := bean.Objects("StorageTypeStats")
+	if !ok {
+		return
+	}
+	for _, entry := range entries {
+		storageType, ok := entry.String("key")
+		if !ok {
+			continue
+		}
+		stats, ok := entry.Object("value")
+		if !ok {
+			continue
+		}
+		for _, attr := range stats.Keys() {
+			if mode, ok := storageTypeModes[attr]; ok {
+				e.BeanGauge(storageTypeCapacityDesc, stats, attr, storageType, mode)
+			}
+		}
+		e.BeanGauge(storageTypeNodesDesc, stats, "nodesInService", storageType)
+	}
+}
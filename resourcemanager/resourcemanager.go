@@ -0,0 +1,130 @@
+package resourcemanager
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/meoww-bot/hadoop_exporter/lib"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "resourcemanager"
+	// metricsPath is the path of the cluster metrics of the REST API.
+	metricsPath = "/ws/v1/cluster/metrics"
+)
+
+// defaultRules are the built-in rules mapping the clusterMetrics to metrics.
+//
+//go:embed rules.yaml
+var defaultRules []byte
+
+// collectors send the metrics of the beans that the rules cannot express.
+var collectors = []lib.BeanCollector{lib.NewRPCCollector(namespace), lib.NewJVMCollector(namespace)}
+
+// Role is the ResourceManager role of the exporter. Its metrics come from the
+// REST API and from /jmx.
+var Role = &lib.Role{
+	Name:          "resourcemanager",
+	Title:         "ResourceManager",
+	Service:       "ResourceManager",
+	Namespace:     namespace,
+	ListenAddress: ":9088",
+	URL:           "http://localhost:8088",
+	Path:          metricsPath,
+	LegacyURLFlag: "resourcemanager.url",
+	Rules:         defaultRules,
+	NewExporter: func(client *lib.JmxClient, rules *lib.Rules) lib.ContextCollector {
+		return NewExporter(client, rules)
+	},
+}
+
+type Exporter struct {
+	client *lib.JmxClient
+	jmx    *lib.JmxClient
+	rules  *lib.Rules
+}
+
+func NewExporter(client *lib.JmxClient, rules *lib.Rules) *Exporter {
+	return &Exporter{
+		client: client,
+		jmx:    client.WithURL(strings.TrimSuffix(client.URL, metricsPath) + "/jmx"),
+		rules:  rules,
+	}
+}
+
+// Describe implements the prometheus.Collector interface. The metrics of the
+// rules depend on the response of the ResourceManager and are not described.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	lib.DescribeScrape(ch)
+}
+
+// CollectContext implements the lib.ContextCollector interface.
+func (e *Exporter) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	scrape := lib.BeginScrape(ctx, e.client.Target())
+	defer scrape.Done(ch)
+
+	data, err := e.client.Fetch(ctx)
+	if err != nil {
+		scrape.FailFetch(err)
+		return
+	}
+	/*
+	  "clusterMetrics": {
+	    "activeNodes": 3,
+	    "rebootedNodes": 0,
+	    "decommissionedNodes": 0,
+	    "unhealthyNodes": 0,
+	    "lostNodes": 0,
+	    "totalNodes": 3,
+	    "totalVirtualCores": 9,
+	    "availableMB": 6144,
+	    "reservedMB": 0,
+	    "appsKilled": 0,
+	    "appsFailed": 1,
+	    "appsRunning": 0,
+	    "appsPending": 0,
+	    "appsCompleted": 9,
+	    "appsSubmitted": 10,
+	    "allocatedMB": 0,
+	    "reservedVirtualCores": 0,
+	    "availableVirtualCores": 9,
+	    "allocatedVirtualCores": 0,
+	    "containersAllocated": 0,
+	    "containersReserved": 0,
+	    "containersPending": 0,
+	    "totalMB": 6144
+	  }
+	*/
+	var f struct {
+		ClusterMetrics map[string]interface{} `json:"clusterMetrics"`
+	}
+	err = json.Unmarshal(data, &f)
+	if err == nil && f.ClusterMetrics == nil {
+		err = fmt.Errorf("no clusterMetrics")
+	}
+	if err != nil {
+		scrape.Fail(lib.StageParse, fmt.Errorf("invalid response: %w", err))
+		return
+	}
+	// The rules see the clusterMetrics as a bean of that name.
+	e.rules.Collect(ch, scrape, []*lib.Bean{lib.NewBean("clusterMetrics", f.ClusterMetrics)})
+
+	// The beans of /jmx, such as those of the RPC servers, add to the
+	// cluster metrics, which are exported even if /jmx fails.
+	data, err = e.jmx.Fetch(ctx)
+	if err != nil {
+		scrape.Error(lib.StageJMX, err)
+		return
+	}
+	beans, err := lib.ParseBeans(data)
+	if err != nil {
+		scrape.Error(lib.StageJMX, err)
+		return
+	}
+	e.rules.Collect(ch, scrape, beans)
+	lib.CollectBeans(lib.NewEmitter(ch, nil, nil), scrape, beans, collectors)
+}